@@ -3,6 +3,7 @@ package common
 import (
 	"os"
 	"os/signal"
+	"sync"
 	"syscall"
 	"time"
 )
@@ -38,6 +39,7 @@ func Exit(code ...interface{}) {
 		}
 	}
 	Log.Info("stopped!\n\n")
+	Log.Close()
 	close(ExitedChannel)
 	time.Sleep(time.Millisecond * 100)
 	if needExit {
@@ -45,6 +47,52 @@ func Exit(code ...interface{}) {
 	}
 }
 
+var (
+	sighupHooks []func()
+	sighupMutex sync.Mutex
+	sighupOnce  sync.Once
+)
+
+// OnSighup регистрирует функцию, которая вызывается при получении SIGHUP
+// Работает только после вызова HandleSighup
+func OnSighup(cb func()) {
+	sighupMutex.Lock()
+	sighupHooks = append(sighupHooks, cb)
+	sighupMutex.Unlock()
+}
+
+// HandleSighup включает обработку SIGHUP (по умолчанию он игнорируется):
+// переоткрывает файлы логов Log (для внешнего logrotate) и вызывает функции, зарегистрированные через OnSighup
+func HandleSighup() {
+	sighupOnce.Do(func() {
+		signalChannel := make(chan os.Signal, 1)
+		signal.Notify(signalChannel, syscall.SIGHUP)
+		go func() {
+			for {
+				select {
+				case <-signalChannel:
+					if err := Log.Reopen(); err != nil {
+						Log.Errorf("SIGHUP: reopen log files: %v", err)
+					}
+					Log.Info("SIGHUP received, log files reopened")
+					sighupMutex.Lock()
+					hooks := append([]func(){}, sighupHooks...)
+					sighupMutex.Unlock()
+					for _, hook := range hooks {
+						hook()
+					}
+				case <-ExitingChannel:
+					// signal.Stop возвращает действие по умолчанию (завершение процесса),
+					// SIGHUP во время Exit должен по-прежнему игнорироваться
+					signal.Stop(signalChannel)
+					signal.Ignore(syscall.SIGHUP)
+					return
+				}
+			}
+		}()
+	})
+}
+
 func init() {
 	signalChannel := make(chan os.Signal, 1)
 	signal.Ignore(syscall.SIGHUP)
@@ -57,9 +105,23 @@ func init() {
 
 	go func() {
 		for signal := range signalChannel {
-			Log.Warn("Signal %#v received, exiting...", signal.String())
+			Log.Warnf("Signal %#v received, exiting...", signal.String())
 			Exit()
 			return
 		}
 	}()
+
+	// SIGUSR1 - логгировать подробнее, SIGUSR2 - короче
+	levelChannel := make(chan os.Signal, 1)
+	signal.Notify(levelChannel, syscall.SIGUSR1, syscall.SIGUSR2)
+	go func() {
+		for signal := range levelChannel {
+			delta := 1
+			if signal == syscall.SIGUSR1 {
+				delta = -1
+			}
+			level := Log.StepLevel(delta)
+			Log.Warnf("Signal %#v received, log level is %v now", signal.String(), level)
+		}
+	}()
 }
@@ -11,12 +11,29 @@ import (
 var (
 	configFilePath  = flag.String("c", "", "Config file")
 	configFilePath1 = flag.String("config", "", "Config file")
+	// logLevelFlag значение флага -log-level, если он зарегистрирован RegisterLogFlags
+	logLevelFlag string
 )
 
+// RegisterLogFlags регистрирует флаг -log-level (спецификация уровней, см. (*Logger)SetLevelSpec),
+// который применяют LoadConfig и ApplyConfig. Вызывается до flag.Parse, если флаг нужен приложению
+//	* fs - *flag.FlagSet, nil - flag.CommandLine
+func RegisterLogFlags(fs *flag.FlagSet) {
+	if fs == nil {
+		fs = flag.CommandLine
+	}
+	fs.StringVar(&logLevelFlag, "log-level", "", "Log levels, e.g. info,pdg=debug,watch=warn")
+}
+
 func LoadConfig(config interface{}, configFile string) error {
 	if !flag.Parsed() {
 		flag.Parse()
 	}
+	if logLevelFlag != "" {
+		if err := Log.SetLevelSpec(logLevelFlag); err != nil {
+			return err
+		}
+	}
 	if *configFilePath != "" {
 		configFile = *configFilePath
 	}
@@ -26,7 +43,7 @@ func LoadConfig(config interface{}, configFile string) error {
 	if !strings.HasSuffix(configFile, ".yaml") {
 		configFile += ".yaml"
 	}
-	Log.Verbose("reading configuration from '%s'...", configFile)
+	Log.Verbosef("reading configuration from '%s'...", configFile)
 	file, err := os.Open(configFile)
 	if err != nil {
 		return Errorf("while reading %s: %v", configFile, err)
@@ -16,16 +16,16 @@ func MD5HashBytes(obj interface{}) []byte {
 	case nil:
 		v = make([]byte, md5.Size*2)
 		if _, err := rand.Read(v); err != nil {
-			Log.Error("Md5HashBytes/Read: %v", err)
+			Log.Errorf("Md5HashBytes/Read: %v", err)
 			return nil
 		}
 	default:
-		Log.Error("unsupported type: %T", obj)
+		Log.Errorf("unsupported type: %T", obj)
 		return nil
 	}
 	h := md5.New()
 	if _, err := h.Write(v); err != nil {
-		Log.Error("Md5HashBytes/Write: %v", err)
+		Log.Errorf("Md5HashBytes/Write: %v", err)
 		return nil
 	}
 	return h.Sum(nil)
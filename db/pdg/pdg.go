@@ -2,6 +2,7 @@ package pdg
 
 import (
 	"bytes"
+	"context"
 	"encoding/gob"
 	"fmt"
 	"os"
@@ -13,7 +14,7 @@ import (
 	"github.com/recoilme/pudge"
 )
 
-var log = common.Log
+var log = common.Log.Named("pdg")
 
 type Db struct {
 	dbs        map[string]*pudge.Db
@@ -190,6 +191,36 @@ func (this *Db) Del(id string, item interface{}) (err error) {
 	return
 }
 
+// GetCtx как Get, но пишет в лог с полями из ctx (см. common.ContextWithFields)
+func (this *Db) GetCtx(ctx context.Context, id string, item interface{}) (found bool, err error) {
+	if found, err = this.Get(id, item); err != nil {
+		log.FromContext(ctx).Warn("get %v %q: %v", this.GetNameFor(item), id, err)
+	} else {
+		log.FromContext(ctx).Debug("get %v %q: found=%v", this.GetNameFor(item), id, found)
+	}
+	return
+}
+
+// PutCtx как Put, но пишет в лог с полями из ctx (см. common.ContextWithFields)
+func (this *Db) PutCtx(ctx context.Context, id string, item interface{}) (err error) {
+	if err = this.Put(id, item); err != nil {
+		log.FromContext(ctx).Warn("put %v %q: %v", this.GetNameFor(item), id, err)
+	} else {
+		log.FromContext(ctx).Debug("put %v %q", this.GetNameFor(item), id)
+	}
+	return
+}
+
+// DelCtx как Del, но пишет в лог с полями из ctx (см. common.ContextWithFields)
+func (this *Db) DelCtx(ctx context.Context, id string, item interface{}) (err error) {
+	if err = this.Del(id, item); err != nil {
+		log.FromContext(ctx).Warn("del %v %q: %v", this.GetNameFor(item), id, err)
+	} else {
+		log.FromContext(ctx).Debug("del %v %q", this.GetNameFor(item), id)
+	}
+	return
+}
+
 // func (this *Db) GetDevice(id string) *hw.Device {
 // 	device := hw.Device{}
 // 	tmp := &[]byte{}
@@ -32,7 +32,7 @@ func TestWork(t *testing.T) {
 		t.Fatal(err)
 	}
 	if !found {
-		t.Fatal("id %q not found", id)
+		t.Fatalf("id %q not found", id)
 	}
 	t.Logf("b: %#v", b)
 	if a.A != b.A {
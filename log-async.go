@@ -0,0 +1,191 @@
+package common
+
+import (
+	"sync"
+)
+
+// AsyncPolicy поведение асинхронного логгера при заполненном буфере
+type AsyncPolicy int
+
+const (
+	// AsyncBlock ждать, пока в буфере освободится место
+	AsyncBlock AsyncPolicy = iota
+	// AsyncDropOldest выбросить самую старую запись из буфера
+	AsyncDropOldest
+	// AsyncDropNewest выбросить новую запись
+	AsyncDropNewest
+)
+
+// asyncQueue кольцевой буфер записей асинхронного логгера
+type asyncQueue struct {
+	mutex   sync.Mutex
+	cond    *sync.Cond
+	records []Record
+	head    int
+	count   int
+	busy    bool
+	closed  bool
+	policy  AsyncPolicy
+	dropped uint64
+	done    chan struct{}
+}
+
+func newAsyncQueue(size int, policy AsyncPolicy) *asyncQueue {
+	q := &asyncQueue{
+		records: make([]Record, size),
+		policy:  policy,
+		done:    make(chan struct{}),
+	}
+	q.cond = sync.NewCond(&q.mutex)
+	return q
+}
+
+// push добавляет копию записи в буфер
+// Возвращает false, если буфер уже закрыт и запись надо вывести синхронно
+func (q *asyncQueue) push(r *Record) bool {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	for q.count == len(q.records) && q.policy == AsyncBlock && !q.closed {
+		q.cond.Wait()
+	}
+	if q.closed {
+		return false
+	}
+	if q.count == len(q.records) {
+		q.dropped++
+		if q.policy != AsyncDropOldest {
+			return true
+		}
+		q.records[q.head] = Record{}
+		q.head = (q.head + 1) % len(q.records)
+		q.count--
+	}
+	q.records[(q.head+q.count)%len(q.records)] = *r
+	q.count++
+	q.cond.Broadcast()
+	return true
+}
+
+// pop забирает запись из буфера, ожидая её появления
+// Возвращает false, если буфер закрыт и пуст
+func (q *asyncQueue) pop(r *Record) bool {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	q.busy = false
+	q.cond.Broadcast()
+	for q.count == 0 {
+		if q.closed {
+			return false
+		}
+		q.cond.Wait()
+	}
+	*r = q.records[q.head]
+	q.records[q.head] = Record{}
+	q.head = (q.head + 1) % len(q.records)
+	q.count--
+	q.busy = true
+	q.cond.Broadcast()
+	return true
+}
+
+// flush ожидает, пока все записи из буфера будут выведены
+func (q *asyncQueue) flush() {
+	q.mutex.Lock()
+	for (q.count > 0 || q.busy) && !q.closed {
+		q.cond.Wait()
+	}
+	q.mutex.Unlock()
+}
+
+// close закрывает буфер и ожидает вывода оставшихся записей
+func (q *asyncQueue) close() {
+	q.mutex.Lock()
+	q.closed = true
+	q.cond.Broadcast()
+	q.mutex.Unlock()
+	<-q.done
+}
+
+// runAsync выводит записи из буфера, пока он не закрыт
+func (l *Logger) runAsync(q *asyncQueue) {
+	var r Record
+	for q.pop(&r) {
+		l.mutex.Lock()
+		l.record = r
+		l.write(&l.record)
+		l.mutex.Unlock()
+	}
+	close(q.done)
+}
+
+// SetAsync включает асинхронный режим: записи складываются в буфер и выводятся в writer'ы фоновой горутиной,
+// поэтому медленный writer не блокирует вызывающего. При Exit буфер выводится полностью.
+//	* size		- размер буфера (в записях), 0 - выключить асинхронный режим
+//	* policy	- AsyncPolicy, что делать при заполненном буфере
+func (l *Logger) SetAsync(size int, policy AsyncPolicy) {
+	var q *asyncQueue
+	var exitWait chan WaitChanResult
+	if size > 0 {
+		q = newAsyncQueue(size, policy)
+		exitWait = ExitWaitChans.Add()
+	}
+	l.stateMutex.Lock()
+	prev, prevExitWait := l.async, l.asyncExitWait
+	l.async, l.asyncExitWait = q, exitWait
+	l.stateMutex.Unlock()
+
+	if prev != nil {
+		ExitWaitChans.Remove(prevExitWait)
+		l.stopAsync(prev)
+	}
+	if q == nil {
+		return
+	}
+	go l.runAsync(q)
+	go func() {
+		if done, ok := <-exitWait; ok {
+			l.stateMutex.Lock()
+			if l.async == q {
+				l.async, l.asyncExitWait = nil, nil
+			}
+			l.stateMutex.Unlock()
+			l.stopAsync(q)
+			done.Done()
+		}
+	}()
+}
+
+// stopAsync выводит оставшиеся в буфере записи и останавливает фоновую горутину
+func (l *Logger) stopAsync(q *asyncQueue) {
+	q.close()
+	q.mutex.Lock()
+	dropped := q.dropped
+	q.dropped = 0
+	q.mutex.Unlock()
+	l.stateMutex.Lock()
+	l.droppedTotal += dropped
+	l.stateMutex.Unlock()
+}
+
+// Flush ожидает вывода всех записей из буфера асинхронного логгера
+func (l *Logger) Flush() {
+	l.stateMutex.Lock()
+	q := l.async
+	l.stateMutex.Unlock()
+	if q != nil {
+		q.flush()
+	}
+}
+
+// Dropped возвращает количество записей, выброшенных асинхронным логгером из-за заполненного буфера
+func (l *Logger) Dropped() (dropped uint64) {
+	l.stateMutex.Lock()
+	defer l.stateMutex.Unlock()
+	dropped = l.droppedTotal
+	if l.async != nil {
+		l.async.mutex.Lock()
+		dropped += l.async.dropped
+		l.async.mutex.Unlock()
+	}
+	return
+}
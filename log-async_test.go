@@ -0,0 +1,63 @@
+package common
+
+import (
+	"bytes"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// blockingWriter пишет в буфер только после закрытия unblock
+type blockingWriter struct {
+	unblock chan struct{}
+	mutex   sync.Mutex
+	buf     bytes.Buffer
+}
+
+func (w *blockingWriter) Write(p []byte) (int, error) {
+	<-w.unblock
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	return w.buf.Write(p)
+}
+
+func (w *blockingWriter) String() string {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	return w.buf.String()
+}
+
+func queued(q *asyncQueue) int {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	return q.count
+}
+
+func TestLoggerAsync(t *testing.T) {
+	w := &blockingWriter{unblock: make(chan struct{})}
+	l := NewLogger(w, LevelDebug)
+	l.SetUseColors(false)
+	l.SetAsync(2, AsyncDropNewest)
+	defer l.SetAsync(0, AsyncBlock)
+
+	// Первая запись забирается фоновой горутиной и блокирует её,
+	// следующие две ложатся в буфер, остальные выбрасываются
+	l.Info("first")
+	for queued(l.async) > 0 {
+		runtime.Gosched()
+	}
+	for i := 0; i < 5; i++ {
+		l.Info("queued ", i)
+	}
+	if dropped := l.Dropped(); dropped != 3 {
+		t.Fatalf("expected 3 dropped records, got %v", dropped)
+	}
+	close(w.unblock)
+	l.Flush()
+	out := w.String()
+	if strings.Count(out, "\n") != 3 || !strings.Contains(out, " first\n") ||
+		!strings.Contains(out, " queued 1\n") || strings.Contains(out, " queued 2\n") {
+		t.Fatalf("unexpected output: %q", out)
+	}
+}
@@ -0,0 +1,43 @@
+package common
+
+import (
+	"strings"
+)
+
+// Skip возвращает дочерний логгер, который пропускает еще n кадров при определении вызывающего
+// Для оберток над логгером: func warn(s ...interface{}) { common.Log.Skip(1).Warn(s...) }
+//	* n - int
+func (l *Logger) Skip(n int) *Logger {
+	child := *l
+	child.skip += n
+	return &child
+}
+
+// At возвращает дочерний логгер, у записей которого вызывающий задан явно
+//	* file	- string
+//	* line	- int
+func (l *Logger) At(file string, line int) *Logger {
+	return l.withCaller(StackFrame{File: file, Line: line})
+}
+
+func (l *Logger) withCaller(frame StackFrame) *Logger {
+	child := *l
+	child.at = &frame
+	return &child
+}
+
+// SetFuncName устанавливает выводить ли имя функции вызывающего вместе с file:line
+//	* funcName - bool
+func (l *Logger) SetFuncName(funcName bool) {
+	l.stateMutex.Lock()
+	l.funcName = funcName
+	l.stateMutex.Unlock()
+}
+
+// shortFuncName возвращает имя функции без пути пакета: "pdg.(*Db).Get"
+func shortFuncName(name string) string {
+	if idx := strings.LastIndexByte(name, '/'); idx >= 0 {
+		return name[idx+1:]
+	}
+	return name
+}
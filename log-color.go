@@ -0,0 +1,77 @@
+package common
+
+import (
+	"io"
+	"os"
+	"strconv"
+)
+
+// Переменные окружения, управляющие выводом с цветом (https://no-color.org, https://force-color.org)
+const (
+	NoColorEnv    = "NO_COLOR"
+	ForceColorEnv = "FORCE_COLOR"
+)
+
+// detectColors определяет, выводить ли в writer с цветом:
+// NO_COLOR выключает цвет, FORCE_COLOR включает, иначе цвет только для терминала
+//	* writer - io.Writer
+func detectColors(writer io.Writer) bool {
+	if os.Getenv(NoColorEnv) != "" {
+		return false
+	}
+	if force := os.Getenv(ForceColorEnv); force != "" {
+		return force != "0" && force != "false"
+	}
+	file, ok := writer.(*os.File)
+	if !ok || os.Getenv("TERM") == "dumb" {
+		return false
+	}
+	info, err := file.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// LevelColor цвета записи уровня: параметры ANSI SGR, например "1;91" - жирный ярко-красный, "" - без цвета
+type LevelColor struct {
+	// Prefix уровень, время и вызывающий
+	Prefix string
+	// Message текст сообщения
+	Message string
+}
+
+// Palette цвета TextEncoder по уровням
+type Palette map[logLevels]LevelColor
+
+// DefaultPalette возвращает цвета по умолчанию: ошибки выделяются красным целиком
+func DefaultPalette() Palette {
+	bold := func(color int) string {
+		return "1;" + strconv.Itoa(color)
+	}
+	return Palette{
+		LevelFatal:   {Prefix: bold(fgHiRed), Message: strconv.Itoa(fgHiRed)},
+		LevelError:   {Prefix: bold(fgHiRed), Message: strconv.Itoa(fgHiRed)},
+		LevelWarn:    {Prefix: bold(fgHiMagenta)},
+		LevelInfo:    {Prefix: bold(fgHiGreen)},
+		LevelVerbose: {Prefix: bold(fgHiCyan)},
+		LevelDebug:   {Prefix: bold(fgHiBlue)},
+	}
+}
+
+var defaultPalette = DefaultPalette()
+
+// appendColor дописывает escape-последовательность цвета
+func appendColor(buf []byte, color string) []byte {
+	buf = append(buf, "\x1b["...)
+	buf = append(buf, color...)
+	return append(buf, 'm')
+}
+
+// SetPalette устанавливает цвета текстового формата (см. TextEncoder), nil - DefaultPalette()
+//	* palette - Palette
+func (l *Logger) SetPalette(palette Palette) {
+	l.mutex.Lock()
+	if encoder, ok := l.encoder.(TextEncoder); ok {
+		encoder.Palette = palette
+		l.encoder = encoder
+	}
+	l.mutex.Unlock()
+}
@@ -0,0 +1,116 @@
+package common
+
+import (
+	"context"
+)
+
+// loggerContextKey ключ логгера в context.Context
+type loggerContextKey struct{}
+
+// TraceIDField имя поля с идентификатором трассировки (см. ContextWithTraceID)
+const TraceIDField = "trace_id"
+
+// ContextWithLogger возвращает контекст с логгером, поля которого попадут в каждую запись,
+// сделанную через FromContext или *Ctx-методы
+//	* ctx	- context.Context
+//	* l		- *Logger
+func ContextWithLogger(ctx context.Context, l *Logger) context.Context {
+	return context.WithValue(ctx, loggerContextKey{}, l)
+}
+
+// LoggerFromContext возвращает логгер из контекста или Log, если его там нет
+//	* ctx - context.Context
+func LoggerFromContext(ctx context.Context) *Logger {
+	if ctx != nil {
+		if l, ok := ctx.Value(loggerContextKey{}).(*Logger); ok {
+			return l
+		}
+	}
+	return Log
+}
+
+// ContextWithFields возвращает контекст, в котором к логгеру добавлены поля (id апдейта, чата и т.п.)
+//	* ctx	- context.Context
+//	* kv	- пары ключ, значение (или Field)
+func ContextWithFields(ctx context.Context, kv ...interface{}) context.Context {
+	return ContextWithLogger(ctx, LoggerFromContext(ctx).With(kv...))
+}
+
+// ContextWithTraceID возвращает контекст с полем trace_id
+//	* ctx		- context.Context
+//	* traceID	- string
+func ContextWithTraceID(ctx context.Context, traceID string) context.Context {
+	return ContextWithFields(ctx, TraceIDField, traceID)
+}
+
+// FromContext возвращает дочерний логгер с полями логгера из контекста
+// Имя и настройки l сохраняются, так что Log.Named("pdg").FromContext(ctx) пишет от имени pdg
+// Буфер FingersCrossed логгера из контекста тоже сохраняется
+//	* ctx - context.Context
+func (l *Logger) FromContext(ctx context.Context) *Logger {
+	if ctx == nil {
+		return l
+	}
+	ctxLogger, ok := ctx.Value(loggerContextKey{}).(*Logger)
+	if !ok || ctxLogger == l || len(ctxLogger.fields) == 0 && (ctxLogger.crossed == nil || l.crossed != nil) {
+		return l
+	}
+	if ctxLogger.loggerCore == l.loggerCore && ctxLogger.component == l.component && len(l.fields) == 0 &&
+		(l.crossed == nil || l.crossed == ctxLogger.crossed) {
+		return ctxLogger
+	}
+	child := l.With(ctxLogger.fields)
+	if child.crossed == nil {
+		child.crossed = ctxLogger.crossed
+	}
+	return child
+}
+
+// VerboseCtx вывести сообщение уровня LevelVerbose с полями из контекста
+//	* ctx	- context.Context
+//	* s		- ...interface{}
+func (l *Logger) VerboseCtx(ctx context.Context, s ...interface{}) {
+
+	l.FromContext(ctx).log(LevelVerbose, s...)
+}
+
+// DebugCtx вывести сообщение уровня LevelDebug с полями из контекста
+//	* ctx	- context.Context
+//	* s		- ...interface{}
+func (l *Logger) DebugCtx(ctx context.Context, s ...interface{}) {
+
+	l.FromContext(ctx).log(LevelDebug, s...)
+}
+
+// InfoCtx вывести сообщение уровня LevelInfo с полями из контекста
+//	* ctx	- context.Context
+//	* s		- ...interface{}
+func (l *Logger) InfoCtx(ctx context.Context, s ...interface{}) {
+
+	l.FromContext(ctx).log(LevelInfo, s...)
+}
+
+// WarnCtx вывести сообщение уровня LevelWarn с полями из контекста
+//	* ctx	- context.Context
+//	* s		- ...interface{}
+func (l *Logger) WarnCtx(ctx context.Context, s ...interface{}) {
+
+	l.FromContext(ctx).log(LevelWarn, s...)
+}
+
+// ErrorCtx вывести сообщение уровня LevelError с полями из контекста
+//	* ctx	- context.Context
+//	* s		- ...interface{}
+func (l *Logger) ErrorCtx(ctx context.Context, s ...interface{}) {
+
+	l.FromContext(ctx).log(LevelError, s...)
+}
+
+// FatalCtx вывести сообщение уровня LevelFatal с полями из контекста и завершиться
+//	* ctx	- context.Context
+//	* s		- ...interface{}
+func (l *Logger) FatalCtx(ctx context.Context, s ...interface{}) {
+
+	l.FromContext(ctx).log(LevelFatal, s...)
+	Exit(-1)
+}
@@ -0,0 +1,393 @@
+package common
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"path/filepath"
+	"strconv"
+	"time"
+	"unicode/utf8"
+)
+
+// Record запись лога, которую энкодер превращает в строку
+type Record struct {
+	Time  time.Time
+	Level logLevels
+	Name  string
+	File  string
+	Line  int
+	// PC адрес вызывающего (как в runtime.Callers), 0 - неизвестен
+	PC uintptr
+	// Func имя функции вызывающего (см. SetFuncName)
+	Func    string
+	Message string
+	Fields  []Field
+	// Err первая ошибка среди аргументов и полей
+	Err error
+	// Stack стек вызовов (см. SetStackTraceLevel)
+	Stack []StackFrame
+}
+
+// Encoder формат вывода записей лога
+// Encode дописывает запись r в buf и возвращает buf, запись должна заканчиваться '\n'
+type Encoder interface {
+	Encode(buf []byte, r *Record, useColors bool) []byte
+}
+
+// TextEncoder текстовый формат (по умолчанию)
+type TextEncoder struct {
+	// Palette цвета по уровням, nil - DefaultPalette()
+	Palette Palette
+	// TimeLayout формат времени: TimeDefault, TimeRFC3339, TimeElapsed или layout для time.Format
+	TimeLayout string
+}
+
+// JSONEncoder формат JSON lines (NDJSON): один объект на строку
+type JSONEncoder struct{}
+
+// LogfmtEncoder формат logfmt: key=value через пробел
+type LogfmtEncoder struct{}
+
+func (e TextEncoder) Encode(buf []byte, r *Record, useColors bool) []byte {
+	logType := logTypes[r.Level]
+	var color LevelColor
+	if useColors {
+		palette := e.Palette
+		if palette == nil {
+			palette = defaultPalette
+		}
+		color = palette[r.Level]
+	}
+	if color.Prefix != "" {
+		buf = appendColor(buf, color.Prefix)
+	}
+
+	buf = append(buf, "    "...)
+	buf = append(buf, logType.prefix...)
+
+	buf = append(buf, ' ')
+	appendTime(&buf, r.Time, e.TimeLayout)
+
+	if r.File != "" {
+		buf = append(buf, " ["...)
+		appendCaller(&buf, r)
+		if r.Func != "" {
+			buf = append(buf, ' ')
+			buf = append(buf, shortFuncName(r.Func)...)
+		}
+		buf = append(buf, "]"...)
+	}
+
+	if color.Prefix != "" {
+		buf = append(buf, "\x1b[0m"...)
+	}
+	buf = append(buf, ' ')
+	if r.Name != "" {
+		buf = append(buf, r.Name...)
+		buf = append(buf, ": "...)
+	}
+	if color.Message != "" {
+		buf = appendColor(buf, color.Message)
+		buf = append(buf, r.Message...)
+		buf = append(buf, "\x1b[0m"...)
+	} else {
+		buf = append(buf, r.Message...)
+	}
+	for _, field := range r.Fields {
+		buf = append(buf, ' ')
+		buf = append(buf, field.Key...)
+		buf = append(buf, '=')
+		appendFieldValue(&buf, field.Value)
+	}
+	buf = append(buf, '\n')
+	appendStackText(&buf, r.Stack)
+	return buf
+}
+
+func (JSONEncoder) Encode(buf []byte, r *Record, _ bool) []byte {
+	buf = append(buf, `{"time":"`...)
+	appendTimestamp(&buf, r.Time)
+	buf = append(buf, `","level":"`...)
+	buf = append(buf, logTypes[r.Level].prefix...)
+	buf = append(buf, '"')
+	if r.File != "" {
+		buf = append(buf, `,"caller":"`...)
+		appendCaller(&buf, r)
+		buf = append(buf, '"')
+	}
+	if r.Func != "" {
+		buf = append(buf, `,"func":`...)
+		appendJSONString(&buf, shortFuncName(r.Func))
+	}
+	if r.Name != "" {
+		buf = append(buf, `,"logger":`...)
+		appendJSONString(&buf, r.Name)
+	}
+	buf = append(buf, `,"msg":`...)
+	appendJSONString(&buf, r.Message)
+	for _, field := range r.Fields {
+		buf = append(buf, ',')
+		appendJSONString(&buf, field.Key)
+		buf = append(buf, ':')
+		appendJSONValue(&buf, field.Value)
+	}
+	if len(r.Stack) > 0 {
+		buf = append(buf, `,"stack":[`...)
+		for idx, frame := range r.Stack {
+			if idx > 0 {
+				buf = append(buf, ',')
+			}
+			start := len(buf)
+			appendFrame(&buf, frame)
+			frameStr := string(buf[start:])
+			buf = buf[:start]
+			appendJSONString(&buf, frameStr)
+		}
+		buf = append(buf, ']')
+	}
+	return append(buf, "}\n"...)
+}
+
+func (LogfmtEncoder) Encode(buf []byte, r *Record, _ bool) []byte {
+	buf = append(buf, "time="...)
+	appendTimestamp(&buf, r.Time)
+	buf = append(buf, " level="...)
+	buf = append(buf, logTypes[r.Level].prefix...)
+	if r.File != "" {
+		buf = append(buf, " caller="...)
+		appendCaller(&buf, r)
+	}
+	if r.Func != "" {
+		buf = append(buf, " func="...)
+		appendFieldValue(&buf, shortFuncName(r.Func))
+	}
+	if r.Name != "" {
+		buf = append(buf, " logger="...)
+		appendFieldValue(&buf, r.Name)
+	}
+	buf = append(buf, " msg="...)
+	appendFieldValue(&buf, r.Message)
+	for _, field := range r.Fields {
+		buf = append(buf, ' ')
+		buf = append(buf, field.Key...)
+		buf = append(buf, '=')
+		appendFieldValue(&buf, field.Value)
+	}
+	if len(r.Stack) > 0 {
+		buf = append(buf, " stack="...)
+		start := len(buf)
+		for idx, frame := range r.Stack {
+			if idx > 0 {
+				buf = append(buf, "; "...)
+			}
+			appendFrame(&buf, frame)
+		}
+		stack := string(buf[start:])
+		buf = strconv.AppendQuote(buf[:start], stack)
+	}
+	return append(buf, '\n')
+}
+
+// appendDate дописывает дату в виде YYYY-MM-DD
+func appendDate(buf *[]byte, t time.Time, sep byte) {
+	year, month, day := t.Date()
+	itoa(buf, year, 4)
+	*buf = append(*buf, sep)
+	itoa(buf, int(month), 2)
+	*buf = append(*buf, sep)
+	itoa(buf, day, 2)
+}
+
+// appendClock дописывает время в виде HH:MM:SS.micro
+func appendClock(buf *[]byte, t time.Time) {
+	hour, min, sec := t.Clock()
+	itoa(buf, hour, 2)
+	*buf = append(*buf, ':')
+	itoa(buf, min, 2)
+	*buf = append(*buf, ':')
+	itoa(buf, sec, 2)
+	*buf = append(*buf, '.')
+	itoa(buf, t.Nanosecond()/1e3, 6)
+}
+
+// appendTimestamp дописывает время в RFC3339 с микросекундами и часовым поясом
+func appendTimestamp(buf *[]byte, t time.Time) {
+	appendDate(buf, t, '-')
+	*buf = append(*buf, 'T')
+	appendClock(buf, t)
+	_, offset := t.Zone()
+	if offset == 0 {
+		*buf = append(*buf, 'Z')
+		return
+	}
+	if offset < 0 {
+		*buf = append(*buf, '-')
+		offset = -offset
+	} else {
+		*buf = append(*buf, '+')
+	}
+	itoa(buf, offset/3600, 2)
+	*buf = append(*buf, ':')
+	itoa(buf, offset%3600/60, 2)
+}
+
+// appendCaller дописывает file:line
+func appendCaller(buf *[]byte, r *Record) {
+	*buf = append(*buf, filepath.Base(r.File)...)
+	if r.Line > 0 {
+		*buf = append(*buf, ':')
+		itoa(buf, r.Line, -1)
+	}
+}
+
+// appendFieldValue дописывает значение поля, при необходимости в кавычках
+func appendFieldValue(buf *[]byte, value interface{}) {
+	start := len(*buf)
+	appendRawValue(buf, value)
+	if str := (*buf)[start:]; needsQuoting(string(str)) {
+		*buf = strconv.AppendQuote((*buf)[:start], string(str))
+	}
+}
+
+// appendRawValue дописывает значение поля как есть, без кавычек
+func appendRawValue(buf *[]byte, value interface{}) {
+	switch v := value.(type) {
+	case string:
+		*buf = append(*buf, v...)
+	case int:
+		*buf = strconv.AppendInt(*buf, int64(v), 10)
+	case int64:
+		*buf = strconv.AppendInt(*buf, v, 10)
+	case int32:
+		*buf = strconv.AppendInt(*buf, int64(v), 10)
+	case uint:
+		*buf = strconv.AppendUint(*buf, uint64(v), 10)
+	case uint64:
+		*buf = strconv.AppendUint(*buf, v, 10)
+	case uint32:
+		*buf = strconv.AppendUint(*buf, uint64(v), 10)
+	case float64:
+		*buf = strconv.AppendFloat(*buf, v, 'g', -1, 64)
+	case float32:
+		*buf = strconv.AppendFloat(*buf, float64(v), 'g', -1, 32)
+	case bool:
+		*buf = strconv.AppendBool(*buf, v)
+	case error:
+		*buf = append(*buf, v.Error()...)
+	case fmt.Stringer:
+		*buf = append(*buf, v.String()...)
+	default:
+		*buf = append(*buf, fmt.Sprint(v)...)
+	}
+}
+
+// needsQuoting проверяет, нужны ли кавычки вокруг значения
+func needsQuoting(str string) bool {
+	if str == "" {
+		return true
+	}
+	for _, ch := range str {
+		if ch <= ' ' || ch == '=' || ch == '"' || ch == 0x7f || ch == utf8.RuneError {
+			return true
+		}
+	}
+	return false
+}
+
+// appendJSONValue дописывает значение поля в JSON
+func appendJSONValue(buf *[]byte, value interface{}) {
+	switch v := value.(type) {
+	case nil:
+		*buf = append(*buf, "null"...)
+	case string:
+		appendJSONString(buf, v)
+	case int:
+		*buf = strconv.AppendInt(*buf, int64(v), 10)
+	case int64:
+		*buf = strconv.AppendInt(*buf, v, 10)
+	case int32:
+		*buf = strconv.AppendInt(*buf, int64(v), 10)
+	case uint:
+		*buf = strconv.AppendUint(*buf, uint64(v), 10)
+	case uint64:
+		*buf = strconv.AppendUint(*buf, v, 10)
+	case uint32:
+		*buf = strconv.AppendUint(*buf, uint64(v), 10)
+	case float64:
+		appendJSONFloat(buf, v, 64)
+	case float32:
+		appendJSONFloat(buf, float64(v), 32)
+	case bool:
+		*buf = strconv.AppendBool(*buf, v)
+	case error:
+		appendJSONString(buf, v.Error())
+	case json.Marshaler:
+		appendJSONMarshal(buf, v)
+	case fmt.Stringer:
+		appendJSONString(buf, v.String())
+	default:
+		appendJSONMarshal(buf, v)
+	}
+}
+
+func appendJSONFloat(buf *[]byte, f float64, bitSize int) {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		appendJSONString(buf, strconv.FormatFloat(f, 'g', -1, bitSize))
+		return
+	}
+	*buf = strconv.AppendFloat(*buf, f, 'g', -1, bitSize)
+}
+
+func appendJSONMarshal(buf *[]byte, value interface{}) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		appendJSONString(buf, fmt.Sprintf("%+v", value))
+		return
+	}
+	*buf = append(*buf, data...)
+}
+
+const hexDigits = "0123456789abcdef"
+
+// appendJSONString дописывает строку в кавычках, экранируя её по правилам JSON
+func appendJSONString(buf *[]byte, str string) {
+	*buf = append(*buf, '"')
+	start := 0
+	for i := 0; i < len(str); {
+		ch := str[i]
+		if ch >= utf8.RuneSelf {
+			r, size := utf8.DecodeRuneInString(str[i:])
+			if r == utf8.RuneError && size == 1 {
+				*buf = append(*buf, str[start:i]...)
+				*buf = append(*buf, "\ufffd"...)
+				i += size
+				start = i
+				continue
+			}
+			i += size
+			continue
+		}
+		if ch >= ' ' && ch != '"' && ch != '\\' {
+			i++
+			continue
+		}
+		*buf = append(*buf, str[start:i]...)
+		switch ch {
+		case '"', '\\':
+			*buf = append(*buf, '\\', ch)
+		case '\n':
+			*buf = append(*buf, '\\', 'n')
+		case '\r':
+			*buf = append(*buf, '\\', 'r')
+		case '\t':
+			*buf = append(*buf, '\\', 't')
+		default:
+			*buf = append(*buf, '\\', 'u', '0', '0', hexDigits[ch>>4], hexDigits[ch&0xf])
+		}
+		i++
+		start = i
+	}
+	*buf = append(*buf, str[start:]...)
+	*buf = append(*buf, '"')
+}
@@ -0,0 +1,176 @@
+package common
+
+import (
+	"math"
+	"time"
+)
+
+// fieldKind тип значения типизированного поля (см. Int, String, ...)
+type fieldKind uint8
+
+// TypedField поле, значение которого не упаковывается в interface{}, пока запись не выводится
+// Создается Int, String и т.п., передается в Log, LogFn и With и превращается в Field только при выводе,
+// поэтому в hook'ах и RecordWriter'ах поля записи - всегда Field
+type TypedField struct {
+	key   string
+	kind  fieldKind
+	num   uint64
+	str   string
+	value interface{}
+}
+
+const (
+	fieldAny fieldKind = iota
+	fieldString
+	fieldInt
+	fieldUint
+	fieldFloat
+	fieldBool
+	fieldDuration
+)
+
+// String поле со строкой, значение не упаковывается в interface{}, пока запись не выводится
+//	* key	- string
+//	* value	- string
+func String(key, value string) TypedField {
+	return TypedField{key: key, kind: fieldString, str: value}
+}
+
+// Int поле с целым числом
+//	* key	- string
+//	* value	- int
+func Int(key string, value int) TypedField {
+	return TypedField{key: key, kind: fieldInt, num: uint64(value)}
+}
+
+// Int64 поле с целым числом
+//	* key	- string
+//	* value	- int64
+func Int64(key string, value int64) TypedField {
+	return TypedField{key: key, kind: fieldInt, num: uint64(value)}
+}
+
+// Uint64 поле с беззнаковым целым числом
+//	* key	- string
+//	* value	- uint64
+func Uint64(key string, value uint64) TypedField {
+	return TypedField{key: key, kind: fieldUint, num: value}
+}
+
+// Float64 поле с числом с плавающей точкой
+//	* key	- string
+//	* value	- float64
+func Float64(key string, value float64) TypedField {
+	return TypedField{key: key, kind: fieldFloat, num: math.Float64bits(value)}
+}
+
+// Bool поле с логическим значением
+//	* key	- string
+//	* value	- bool
+func Bool(key string, value bool) TypedField {
+	field := TypedField{key: key, kind: fieldBool}
+	if value {
+		field.num = 1
+	}
+	return field
+}
+
+// Duration поле с длительностью
+//	* key	- string
+//	* value	- time.Duration
+func Duration(key string, value time.Duration) TypedField {
+	return TypedField{key: key, kind: fieldDuration, num: uint64(value)}
+}
+
+// Err поле "error" с ошибкой, ошибка попадает в Record.Err (стек, см. SetStackTraceLevel)
+//	* err - error
+func Err(err error) TypedField {
+	return TypedField{key: "error", value: err}
+}
+
+// Any поле с произвольным значением
+//	* key	- string
+//	* value	- interface{}
+func Any(key string, value interface{}) TypedField {
+	return TypedField{key: key, value: value}
+}
+
+// field упаковывает типизированное значение в Field, чтобы его можно было вывести
+func (typed TypedField) field() Field {
+	field := Field{Key: typed.key, Value: typed.value}
+	switch typed.kind {
+	case fieldString:
+		field.Value = typed.str
+	case fieldInt:
+		field.Value = int64(typed.num)
+	case fieldUint:
+		field.Value = typed.num
+	case fieldFloat:
+		field.Value = math.Float64frombits(typed.num)
+	case fieldBool:
+		field.Value = typed.num != 0
+	case fieldDuration:
+		field.Value = time.Duration(typed.num)
+	}
+	return field
+}
+
+// Enabled проверяет, что сообщение уровня level будет выведено (или отложено, см. FingersCrossed)
+// Позволяет не готовить дорогие аргументы: if common.Log.Enabled(common.LevelDebug) { ... }
+//	* level - logLevels
+func (l *Logger) Enabled(level logLevels) bool {
+	return l.enabled(level)
+}
+
+// Log выводит сообщение уровня level с полями
+// Если уровень выключен, ничего не делает и не выделяет память, в отличие от Debug и т.п.
+// с аргументами-переменными: common.Log.Log(common.LevelDebug, "update", common.Int64("id", id))
+//	* level		- logLevels
+//	* message	- string
+//	* fields	- поля (Int, String, ...)
+func (l *Logger) Log(level logLevels, message string, fields ...TypedField) {
+	if l.enabled(level) {
+		l.logFields(level, message, fields)
+	}
+}
+
+// LogFn выводит сообщение уровня level, которое формируется, только если уровень включен:
+// common.Log.LogFn(common.LevelDebug, func() string { return dump(update) })
+//	* level		- logLevels
+//	* message	- func() string
+//	* fields	- поля (Int, String, ...)
+func (l *Logger) LogFn(level logLevels, message func() string, fields ...TypedField) {
+	if l.enabled(level) {
+		l.logFields(level, message(), fields)
+	}
+}
+
+// logFields выводит сообщение с полями логгера и полями fields, fields не сохраняется
+func (l *Logger) logFields(level logLevels, message string, fields []TypedField) {
+	all := l.fields
+	if len(fields) > 0 {
+		all = make([]Field, len(l.fields), len(l.fields)+len(fields))
+		copy(all, l.fields)
+		for _, typed := range fields {
+			all = append(all, typed.field())
+		}
+	}
+	l.writeToOut(level, message, all, findError(nil, all))
+}
+
+// resolveLazy вычисляет аргументы-функции func() string, s не изменяется
+func resolveLazy(s []interface{}) []interface{} {
+	var result []interface{}
+	for idx, arg := range s {
+		if fn, ok := arg.(func() string); ok {
+			if result == nil {
+				result = append([]interface{}(nil), s...)
+			}
+			result[idx] = fn()
+		}
+	}
+	if result == nil {
+		return s
+	}
+	return result
+}
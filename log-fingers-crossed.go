@@ -0,0 +1,152 @@
+package common
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// FingersCrossedSize сколько записей хранит буфер FingersCrossed (по умолчанию 100), старые вытесняются
+type FingersCrossedSize int
+
+// DefaultFingersCrossedSize размер буфера FingersCrossed по умолчанию
+const DefaultFingersCrossedSize = 100
+
+// fingersCrossedEntry отложенная запись и логгер, которым она сделана
+type fingersCrossedEntry struct {
+	logger *Logger
+	record Record
+}
+
+// fingersCrossed буфер записей ниже уровня логгера для одной области (апдейта, запроса)
+type fingersCrossed struct {
+	level logLevels
+
+	mutex     sync.Mutex
+	entries   []fingersCrossedEntry
+	next      int
+	dropped   int
+	triggered bool
+	ended     bool
+}
+
+// FingersCrossed возвращает дочерний логгер, который откладывает записи ниже уровня логгера в буфер
+// Если в области выводится запись уровня LevelError или выше, отложенные записи выводятся перед ней,
+// а дальнейшие записи области выводятся сразу. end отбрасывает отложенные записи и завершает область:
+// scoped, end := common.Log.FingersCrossed(); defer end()
+// Дочерние логгеры scoped (With, Named) разделяют с ним буфер
+//	* params - FingersCrossedSize, logLevels (минимальный откладываемый уровень, по умолчанию LevelDebug)
+func (l *Logger) FingersCrossed(params ...interface{}) (scoped *Logger, end func()) {
+	size := DefaultFingersCrossedSize
+	crossed := &fingersCrossed{level: LevelDebug}
+	for _, param := range params {
+		switch param := param.(type) {
+		case FingersCrossedSize:
+			if param > 0 {
+				size = int(param)
+			}
+		case logLevels:
+			crossed.level = param
+		}
+	}
+	crossed.entries = make([]fingersCrossedEntry, 0, size)
+	child := *l
+	child.crossed = crossed
+	return &child, crossed.end
+}
+
+// ContextWithFingersCrossed возвращает контекст с логгером FingersCrossed на основе логгера из контекста
+// Записи *Ctx-методов и FromContext с этим контекстом откладываются до ошибки или вызова end:
+// ctx, end := common.ContextWithFingersCrossed(ctx); defer end()
+//	* ctx		- context.Context
+//	* params	- см. FingersCrossed
+func ContextWithFingersCrossed(ctx context.Context, params ...interface{}) (context.Context, func()) {
+	scoped, end := LoggerFromContext(ctx).FingersCrossed(params...)
+	return ContextWithLogger(ctx, scoped), end
+}
+
+// enabled проверяет, что запись уровня level будет выведена или отложена
+func (l *Logger) enabled(level logLevels) bool {
+	return l.getLevel() <= level || l.crossed != nil && l.crossed.buffers(level)
+}
+
+// buffers проверяет, что запись уровня level может быть отложена
+func (crossed *fingersCrossed) buffers(level logLevels) bool {
+	if level < crossed.level {
+		return false
+	}
+	crossed.mutex.Lock()
+	defer crossed.mutex.Unlock()
+	return !crossed.ended
+}
+
+// pass решает судьбу записи области: false - запись отложена или отброшена,
+// true - запись надо вывести (отложенные записи при этом уже выведены)
+//	* l - *Logger, которым сделана запись
+//	* r - *Record
+func (crossed *fingersCrossed) pass(l *Logger, r *Record) bool {
+	crossed.mutex.Lock()
+	if crossed.ended {
+		crossed.mutex.Unlock()
+		return r.Level >= l.getLevel()
+	}
+	if crossed.triggered {
+		crossed.mutex.Unlock()
+		return r.Level >= crossed.level || r.Level >= l.getLevel()
+	}
+	if r.Level < l.getLevel() {
+		if r.Level >= crossed.level {
+			crossed.add(l, r)
+		}
+		crossed.mutex.Unlock()
+		return false
+	}
+	if r.Level < LevelError {
+		crossed.mutex.Unlock()
+		return true
+	}
+	crossed.triggered = true
+	entries, dropped := crossed.take()
+	crossed.mutex.Unlock()
+
+	if dropped > 0 && len(entries) > 0 {
+		first := entries[0]
+		notice := first.record
+		notice.Message = fmt.Sprintf("... %d earlier records dropped", dropped)
+		notice.Fields, notice.Stack = first.logger.fields, nil
+		first.logger.output(&notice)
+	}
+	for idx := range entries {
+		entries[idx].logger.output(&entries[idx].record)
+	}
+	return true
+}
+
+// add добавляет запись в буфер, вытесняя самую старую, crossed.mutex должен быть захвачен
+func (crossed *fingersCrossed) add(l *Logger, r *Record) {
+	entry := fingersCrossedEntry{logger: l, record: *r}
+	if len(crossed.entries) < cap(crossed.entries) {
+		crossed.entries = append(crossed.entries, entry)
+		return
+	}
+	crossed.entries[crossed.next] = entry
+	crossed.next = (crossed.next + 1) % len(crossed.entries)
+	crossed.dropped++
+}
+
+// take забирает отложенные записи по порядку, crossed.mutex должен быть захвачен
+func (crossed *fingersCrossed) take() (entries []fingersCrossedEntry, dropped int) {
+	entries = append(entries, crossed.entries[crossed.next:]...)
+	entries = append(entries, crossed.entries[:crossed.next]...)
+	dropped = crossed.dropped
+	crossed.entries, crossed.next, crossed.dropped = nil, 0, 0
+	return
+}
+
+// end отбрасывает отложенные записи, записи ниже уровня логгера больше не откладываются
+func (crossed *fingersCrossed) end() {
+	crossed.mutex.Lock()
+	defer crossed.mutex.Unlock()
+	crossed.ended = true
+	crossed.entries, crossed.next, crossed.dropped = nil, 0, 0
+}
@@ -0,0 +1,122 @@
+package common
+
+import (
+	"fmt"
+	"sync/atomic"
+)
+
+// Hook обработчик записей (оповещения, отправка ошибок во внешние системы и т.п.)
+// Вызывается в отдельной горутине, запись r можно сохранять. Записи, которые hook выводит в логгер сам,
+// тоже попадают в hook, если подходят по уровню
+type Hook func(r *Record)
+
+// HookQueueSize параметр AddHook: сколько записей может ждать обработки (по умолчанию DefaultHookQueueSize)
+type HookQueueSize int
+
+// DefaultHookQueueSize размер очереди hook'а по умолчанию
+const DefaultHookQueueSize = 256
+
+// logHook hook со своей очередью и горутиной, чтобы медленный hook не задерживал остальные
+type logHook struct {
+	hook    Hook
+	level   logLevels
+	queue   chan *Record
+	dropped uint64 // доступ только через atomic
+}
+
+// AddHook добавляет hook, который вызывается для записей уровня не ниже заданного
+// Запись не ждет hook: при заполненной очереди она выбрасывается (см. HooksDropped).
+// При Exit очередь обрабатывается полностью
+//	* hook		- Hook
+//	* params	- logLevels (минимальный уровень, по умолчанию LevelError), HookQueueSize
+func (l *Logger) AddHook(hook Hook, params ...interface{}) {
+	h := &logHook{hook: hook, level: LevelError}
+	size := DefaultHookQueueSize
+	for _, param := range params {
+		switch param := param.(type) {
+		case logLevels:
+			h.level = param
+		case HookQueueSize:
+			size = int(param)
+		}
+	}
+	if size < 1 {
+		size = 1
+	}
+	h.queue = make(chan *Record, size)
+	exitWait := ExitWaitChans.Add()
+
+	l.stateMutex.Lock()
+	l.hooks = append(l.hooks, h)
+	l.stateMutex.Unlock()
+	go l.runHook(h, exitWait)
+}
+
+// runHook вызывает hook для записей из очереди, при Exit обрабатывает оставшиеся и удаляет hook
+func (l *Logger) runHook(h *logHook, exitWait chan WaitChanResult) {
+	for {
+		select {
+		case r := <-h.queue:
+			l.callHook(h, r)
+		case done, ok := <-exitWait:
+			l.stateMutex.Lock()
+			for i, hook := range l.hooks {
+				if hook == h {
+					l.hooks = append(l.hooks[:i:i], l.hooks[i+1:]...)
+					break
+				}
+			}
+			l.hooksDropped += atomic.LoadUint64(&h.dropped)
+			l.stateMutex.Unlock()
+			for len(h.queue) > 0 {
+				l.callHook(h, <-h.queue)
+			}
+			if ok {
+				done.Done()
+			}
+			return
+		}
+	}
+}
+
+// callHook вызывает hook, паника в нем выводится в запасной writer (см. SetFallbackWriter)
+func (l *Logger) callHook(h *logHook, r *Record) {
+	defer func() {
+		if err := recover(); err != nil {
+			l.mutex.Lock()
+			if l.fallback != nil {
+				fmt.Fprintf(l.fallback, "log hook panic: %v\n", err)
+			}
+			l.mutex.Unlock()
+		}
+	}()
+	h.hook(r)
+}
+
+// runHooks ставит запись в очереди подходящих hook'ов
+//	* hooks	- []*logHook
+//	* r		- *Record
+func runHooks(hooks []*logHook, r *Record) {
+	for _, h := range hooks {
+		if r.Level < h.level {
+			continue
+		}
+		record := *r
+		select {
+		case h.queue <- &record:
+		default:
+			atomic.AddUint64(&h.dropped, 1)
+		}
+	}
+}
+
+// HooksDropped возвращает количество записей, не переданных hook'ам из-за заполненной очереди
+func (l *Logger) HooksDropped() (dropped uint64) {
+	l.stateMutex.Lock()
+	defer l.stateMutex.Unlock()
+	dropped = l.hooksDropped
+	for _, h := range l.hooks {
+		dropped += atomic.LoadUint64(&h.dropped)
+	}
+	return
+}
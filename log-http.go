@@ -0,0 +1,64 @@
+package common
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// LevelsHandler возвращает http.Handler для просмотра и изменения уровней логгинга на лету
+//	GET		- текущие уровни в виде спецификации, например "info,pdg=debug"
+//	POST/PUT	- новая спецификация (см. SetLevelSpec) в параметре spec адреса или в теле запроса
+//				  (как есть или в форме spec=...), в ответе - текущие уровни
+func (l *Logger) LevelsHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet, http.MethodHead:
+		case http.MethodPost, http.MethodPut:
+			spec, err := levelSpecFromRequest(r)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			if err := l.SetLevelSpec(spec); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			l.Warnf("log levels changed by %v: %v", r.RemoteAddr, spec)
+		default:
+			w.Header().Set("Allow", "GET, HEAD, POST, PUT")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		fmt.Fprintln(w, l.LevelSpec())
+	})
+}
+
+// levelSpecFromRequest возвращает спецификацию уровней из параметра spec адреса, а если его нет - из тела запроса
+// Тело может быть самой спецификацией или формой (curl -d 'spec=debug')
+func levelSpecFromRequest(r *http.Request) (spec string, err error) {
+	if values := r.URL.Query(); len(values["spec"]) > 0 {
+		spec = values.Get("spec")
+	} else {
+		body, err := ioutil.ReadAll(io.LimitReader(r.Body, 4096))
+		if err != nil {
+			return "", err
+		}
+		spec = strings.TrimSpace(string(body))
+		if strings.HasPrefix(r.Header.Get("Content-Type"), "application/x-www-form-urlencoded") || strings.HasPrefix(spec, "spec=") {
+			form, err := url.ParseQuery(spec)
+			if err != nil {
+				return "", err
+			}
+			spec = form.Get("spec")
+		}
+	}
+	if spec = strings.TrimSpace(spec); spec == "" {
+		return "", Errorf("empty log level spec")
+	}
+	return spec, nil
+}
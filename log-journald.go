@@ -0,0 +1,177 @@
+package common
+
+import (
+	"encoding/binary"
+	"io/ioutil"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// JournaldSocket путь к сокету systemd-journald по умолчанию
+const JournaldSocket = "/run/systemd/journal/socket"
+
+// JournaldWriter writer, отправляющий записи в systemd-journald по его native-протоколу
+// Уровень передается в PRIORITY, вызывающий - в CODE_FILE и CODE_LINE, поля записи - в полях журнала
+type JournaldWriter struct {
+	appName string
+
+	mutex  sync.Mutex
+	conn   *net.UnixConn
+	addr   *net.UnixAddr
+	closed bool
+	buf    []byte
+	value  []byte
+}
+
+// NewJournaldWriter создает writer в journald
+//	* socket	- путь к сокету journald, "" - JournaldSocket
+//	* params	- SyslogAppName (SYSLOG_IDENTIFIER)
+func NewJournaldWriter(socket string, params ...interface{}) (w *JournaldWriter, err error) {
+	if socket == "" {
+		socket = JournaldSocket
+	}
+	w = &JournaldWriter{
+		appName: defaultAppName(),
+		addr:    &net.UnixAddr{Name: socket, Net: "unixgram"},
+	}
+	for _, param := range params {
+		switch param := param.(type) {
+		case SyslogAppName:
+			w.appName = string(param)
+		}
+	}
+	if _, err = os.Stat(socket); err != nil {
+		return nil, err
+	}
+	if w.conn, err = net.ListenUnixgram("unixgram", &net.UnixAddr{Net: "unixgram"}); err != nil {
+		return nil, err
+	}
+	return
+}
+
+// WriteRecord отправляет запись в journald
+func (w *JournaldWriter) WriteRecord(r *Record) error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	if w.closed {
+		return os.ErrClosed
+	}
+	w.buf = w.buf[:0]
+	w.appendField("MESSAGE", []byte(strings.TrimRight(r.Message, "\n")))
+	w.value = strconv.AppendInt(w.value[:0], int64(syslogSeverity(r.Level)), 10)
+	w.appendField("PRIORITY", w.value)
+	w.appendField("SYSLOG_IDENTIFIER", []byte(w.appName))
+	if r.File != "" {
+		w.appendField("CODE_FILE", []byte(r.File))
+		if r.Line > 0 {
+			w.value = strconv.AppendInt(w.value[:0], int64(r.Line), 10)
+			w.appendField("CODE_LINE", w.value)
+		}
+	}
+	if r.Func != "" {
+		w.appendField("CODE_FUNC", []byte(r.Func))
+	}
+	if r.Name != "" {
+		w.appendField("LOGGER", []byte(r.Name))
+	}
+	for _, field := range r.Fields {
+		w.value = w.value[:0]
+		appendRawValue(&w.value, field.Value)
+		w.appendField(journaldFieldName(field.Key), w.value)
+	}
+	return w.send()
+}
+
+// journaldFieldName приводит имя поля к допустимому в journald: A-Z, 0-9 и '_', не начинается с '_' или цифры
+func journaldFieldName(key string) string {
+	name := []byte(strings.ToUpper(key))
+	for i, ch := range name {
+		if !(ch >= 'A' && ch <= 'Z' || ch >= '0' && ch <= '9' || ch == '_') {
+			name[i] = '_'
+		}
+	}
+	if len(name) == 0 || name[0] == '_' || name[0] >= '0' && name[0] <= '9' {
+		name = append([]byte("F_"), name...)
+	}
+	if len(name) > 64 {
+		name = name[:64]
+	}
+	return string(name)
+}
+
+// appendField дописывает поле: KEY=value\n или, если в значении есть перевод строки,
+// KEY\n + длина (64 бита, little endian) + value + \n
+func (w *JournaldWriter) appendField(name string, value []byte) {
+	w.buf = append(w.buf, name...)
+	for _, ch := range value {
+		if ch == '\n' {
+			w.buf = append(w.buf, '\n')
+			var size [8]byte
+			binary.LittleEndian.PutUint64(size[:], uint64(len(value)))
+			w.buf = append(w.buf, size[:]...)
+			w.buf = append(w.buf, value...)
+			w.buf = append(w.buf, '\n')
+			return
+		}
+	}
+	w.buf = append(w.buf, '=')
+	w.buf = append(w.buf, value...)
+	w.buf = append(w.buf, '\n')
+}
+
+// send отправляет w.buf одной датаграммой, а если она слишком большая -
+// через временный файл, дескриптор которого передается journald
+func (w *JournaldWriter) send() error {
+	w.conn.SetWriteDeadline(time.Now().Add(5 * time.Second))
+	_, err := w.conn.WriteToUnix(w.buf, w.addr)
+	if err == nil {
+		return nil
+	}
+	if opErr, ok := err.(*net.OpError); !ok || !isMsgSizeError(opErr.Err) {
+		return err
+	}
+	file, err := ioutil.TempFile("/dev/shm", "journal-")
+	if err != nil {
+		if file, err = ioutil.TempFile("", "journal-"); err != nil {
+			return err
+		}
+	}
+	defer file.Close()
+	os.Remove(file.Name())
+	if _, err = file.Write(w.buf); err != nil {
+		return err
+	}
+	_, _, err = w.conn.WriteMsgUnix(nil, syscall.UnixRights(int(file.Fd())), w.addr)
+	return err
+}
+
+func isMsgSizeError(err error) bool {
+	if syscallErr, ok := err.(*os.SyscallError); ok {
+		err = syscallErr.Err
+	}
+	return err == syscall.EMSGSIZE || err == syscall.ENOBUFS
+}
+
+// Write отправляет p в journald как сообщение уровня LevelInfo
+func (w *JournaldWriter) Write(p []byte) (int, error) {
+	if err := w.WriteRecord(&Record{Time: time.Now(), Level: LevelInfo, Message: string(p)}); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// Close закрывает сокет
+func (w *JournaldWriter) Close() error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+	return w.conn.Close()
+}
@@ -0,0 +1,235 @@
+package common
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync/atomic"
+)
+
+// LogLevelEnv переменная окружения со спецификацией уровней логгинга, например "info,pdg=debug,watch=warn"
+const LogLevelEnv = "LOG_LEVEL"
+
+// levelInherit уровень именованного логгера не задан - используется уровень родителя
+const levelInherit logLevels = -1
+
+var levelNames = map[logLevels]string{
+	LevelDebug:   "debug",
+	LevelVerbose: "verbose",
+	LevelInfo:    "info",
+	LevelWarn:    "warn",
+	LevelError:   "error",
+	LevelFatal:   "fatal",
+	LevelOff:     "off",
+}
+
+// String возвращает название уровня: debug, verbose, info, warn, error, fatal
+func (level logLevels) String() string {
+	if name, ok := levelNames[level]; ok {
+		return name
+	}
+	return fmt.Sprintf("level(%d)", int(level))
+}
+
+// ParseLevel разбирает название уровня логгинга (без учета регистра):
+// debug/dbg, verbose/vrb, info/inf, warn/warning/wrn, error/err, fatal/ftl, off
+func ParseLevel(str string) (logLevels, error) {
+	str = strings.ToLower(strings.TrimSpace(str))
+	if str == "" {
+		return 0, withStack(errors.New("empty log level"), 0)
+	}
+	for level, name := range levelNames {
+		// У LevelOff нет префикса, пустой префикс не совпадает ни с чем
+		if str == name || logTypes[level].prefix != "" && str == logTypes[level].prefix {
+			return level, nil
+		}
+	}
+	if str == "warning" {
+		return LevelWarn, nil
+	}
+	return 0, withStack(fmt.Errorf("unknown log level %q", str), 0)
+}
+
+// logComponent настройки именованного логгера, общие для всех логгеров с этим именем
+type logComponent struct {
+	// counts первым полем для выравнивания 64-битных счетчиков (см. MetricsHandler)
+	counts levelCounters
+	name   string
+	parent *logComponent
+	// level и stackLevel - logLevels или levelInherit, доступ только через atomic
+	level      int32
+	stackLevel int32
+}
+
+// getComponent возвращает (создает) настройки именованного логгера
+//	* name - имя, вложенные имена разделяются точкой: "pdg.backup"
+func (l *Logger) getComponent(name string) *logComponent {
+	l.stateMutex.Lock()
+	defer l.stateMutex.Unlock()
+	return l.componentLocked(name)
+}
+
+func (l *Logger) componentLocked(name string) *logComponent {
+	if c, ok := l.components[name]; ok {
+		return c
+	}
+	if l.components == nil {
+		l.components = make(map[string]*logComponent, 8)
+	}
+	c := &logComponent{name: name, level: int32(levelInherit), stackLevel: int32(levelInherit)}
+	if idx := strings.LastIndexByte(name, '.'); idx > 0 {
+		c.parent = l.componentLocked(name[:idx])
+	}
+	l.components[name] = c
+	return c
+}
+
+// Named возвращает дочерний логгер с именем (компонентом), для которого можно задать свой уровень логгинга
+// Имя выводится в каждой строке, имена вложенных логгеров разделяются точкой
+//	* name - string
+func (l *Logger) Named(name string) *Logger {
+	if name == "" {
+		return l
+	}
+	if l.component != nil {
+		name = l.component.name + "." + name
+	}
+	child := *l
+	child.component = l.getComponent(name)
+	return &child
+}
+
+// Name возвращает имя логгера (пустое для корневого)
+func (l *Logger) Name() string {
+	if l.component == nil {
+		return ""
+	}
+	return l.component.name
+}
+
+// getLevel возвращает действующий уровень логгинга с учетом именованных родителей
+func (l *Logger) getLevel() logLevels {
+	for c := l.component; c != nil; c = c.parent {
+		if level := logLevels(atomic.LoadInt32(&c.level)); level != levelInherit {
+			return level
+		}
+	}
+	return logLevels(atomic.LoadInt32(&l.level))
+}
+
+// levelPtr возвращает уровень, который меняет SetLogLevel: именованного логгера или общий
+func (l *Logger) levelPtr() *int32 {
+	if l.component != nil {
+		return &l.component.level
+	}
+	return &l.level
+}
+
+// StepLevel изменяет уровень логгинга на delta шагов: отрицательный delta - подробнее (до LevelDebug),
+// положительный - короче (до LevelFatal). LevelOff считается уровнем LevelFatal, так что
+// StepLevel(-1) после off включает LevelError. Для именованного логгера меняется только его уровень
+// Возвращает новый уровень
+//	* delta - int
+func (l *Logger) StepLevel(delta int) logLevels {
+	ptr := l.levelPtr()
+	for {
+		old := atomic.LoadInt32(ptr)
+		level := l.getLevel()
+		if level > LevelFatal {
+			level = LevelFatal
+		}
+		level += logLevels(delta)
+		if level < LevelDebug {
+			level = LevelDebug
+		} else if level > LevelFatal {
+			level = LevelFatal
+		}
+		if atomic.CompareAndSwapInt32(ptr, old, int32(level)) {
+			return level
+		}
+	}
+}
+
+// Levels возвращает общий уровень (с пустым именем) и явно заданные уровни именованных логгеров
+func (l *Logger) Levels() map[string]logLevels {
+	levels := map[string]logLevels{"": logLevels(atomic.LoadInt32(&l.level))}
+	l.stateMutex.Lock()
+	defer l.stateMutex.Unlock()
+	for name, c := range l.components {
+		if level := logLevels(atomic.LoadInt32(&c.level)); level != levelInherit {
+			levels[name] = level
+		}
+	}
+	return levels
+}
+
+// LevelSpec возвращает текущие уровни в виде спецификации для SetLevelSpec, например "info,pdg=debug"
+func (l *Logger) LevelSpec() string {
+	levels := l.Levels()
+	names := make([]string, 0, len(levels))
+	for name := range levels {
+		if name != "" {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	spec := levels[""].String()
+	for _, name := range names {
+		spec += "," + name + "=" + levels[name].String()
+	}
+	return spec
+}
+
+// SetLevelSpec устанавливает уровни логгинга по спецификации вида "info,pdg=debug,watch=warn":
+// уровень без имени задает общий уровень, name=level - уровень именованного логгера (и вложенных в него),
+// name=inherit - уровень именованного логгера снова берется у родителя
+// Более поздние элементы переопределяют более ранние
+//	* spec - string
+func (l *Logger) SetLevelSpec(spec string) error {
+	type item struct {
+		name  string
+		level logLevels
+	}
+	items := make([]item, 0, 4)
+	for _, part := range strings.Split(spec, ",") {
+		if part = strings.TrimSpace(part); part == "" {
+			continue
+		}
+		name, levelStr := "", part
+		if idx := strings.IndexByte(part, '='); idx >= 0 {
+			name, levelStr = strings.TrimSpace(part[:idx]), part[idx+1:]
+		}
+		level, err := ParseLevel(levelStr)
+		if err != nil && name != "" && strings.TrimSpace(levelStr) == "inherit" {
+			level, err = levelInherit, nil
+		}
+		if err != nil {
+			return withStack(fmt.Errorf("in log level spec %q: %w", spec, err), 0)
+		}
+		items = append(items, item{name, level})
+	}
+	for _, item := range items {
+		if item.name == "" {
+			atomic.StoreInt32(&l.level, int32(item.level))
+		} else {
+			atomic.StoreInt32(&l.getComponent(item.name).level, int32(item.level))
+		}
+	}
+	return nil
+}
+
+// LogConfig настройки логгера для конфигурационного файла
+type LogConfig struct {
+	// Level спецификация уровней, см. (*Logger)SetLevelSpec
+	Level string `yaml:"level"`
+}
+
+// ApplyConfig применяет настройки логгера из конфигурации
+// Переменная окружения LOG_LEVEL и флаг -log-level (см. RegisterLogFlags) имеют приоритет над конфигурацией
+//	* config - LogConfig
+func (l *Logger) ApplyConfig(config LogConfig) error {
+	spec := []string{config.Level, os.Getenv(LogLevelEnv), logLevelFlag}
+	return l.SetLevelSpec(strings.Join(spec, ","))
+}
@@ -0,0 +1,165 @@
+package common
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultMemoryRecords размер MemoryWriter по умолчанию
+const DefaultMemoryRecords = 500
+
+// MemoryWriter writer, хранящий в памяти последние записи (для тестов и отладочной страницы)
+type MemoryWriter struct {
+	mutex   sync.Mutex
+	records []Record
+	head    int
+	count   int
+	total   uint64
+}
+
+// NewMemoryWriter создает writer, хранящий последние size записей:
+// mem := common.NewMemoryWriter(500); common.Log.AddWriter(mem)
+//	* size - int, 0 - DefaultMemoryRecords
+func NewMemoryWriter(size int) *MemoryWriter {
+	if size <= 0 {
+		size = DefaultMemoryRecords
+	}
+	return &MemoryWriter{records: make([]Record, size)}
+}
+
+// WriteRecord сохраняет копию записи, вытесняя самую старую
+func (w *MemoryWriter) WriteRecord(r *Record) error {
+	record := *r
+	record.Fields = append([]Field(nil), r.Fields...)
+	record.Stack = append([]StackFrame(nil), r.Stack...)
+	w.mutex.Lock()
+	idx := (w.head + w.count) % len(w.records)
+	if w.count == len(w.records) {
+		w.head = (w.head + 1) % len(w.records)
+	} else {
+		w.count++
+	}
+	w.records[idx] = record
+	w.total++
+	w.mutex.Unlock()
+	return nil
+}
+
+// Write сохраняет p как сообщение уровня LevelInfo
+func (w *MemoryWriter) Write(p []byte) (int, error) {
+	w.WriteRecord(&Record{Time: time.Now(), Level: LevelInfo, Message: strings.TrimSuffix(string(p), "\n")})
+	return len(p), nil
+}
+
+// matchRecord проверяет запись по фильтрам Records
+func matchRecord(r *Record, filters []interface{}) bool {
+	for _, filter := range filters {
+		switch filter := filter.(type) {
+		case logLevels:
+			if r.Level < filter {
+				return false
+			}
+		case string:
+			if !strings.Contains(r.Message, filter) {
+				return false
+			}
+		case func(r *Record) bool:
+			if !filter(r) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// Each вызывает cb для сохраненных записей, подходящих под фильтры, от старых к новым,
+// пока cb возвращает true. Запись r нельзя сохранять после возврата из cb
+//	* cb		- func(r *Record) bool
+//	* filters	- logLevels (минимальный уровень), string (подстрока сообщения), func(r *Record) bool
+func (w *MemoryWriter) Each(cb func(r *Record) bool, filters ...interface{}) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	for i := 0; i < w.count; i++ {
+		r := &w.records[(w.head+i)%len(w.records)]
+		if matchRecord(r, filters) && !cb(r) {
+			return
+		}
+	}
+}
+
+// Records возвращает копию сохраненных записей, подходящих под фильтры, от старых к новым
+//	* filters - logLevels (минимальный уровень), string (подстрока сообщения), func(r *Record) bool
+func (w *MemoryWriter) Records(filters ...interface{}) (records []Record) {
+	w.Each(func(r *Record) bool {
+		records = append(records, *r)
+		return true
+	}, filters...)
+	return
+}
+
+// Contains проверяет, есть ли запись, подходящая под фильтры
+//	* filters - logLevels (минимальный уровень), string (подстрока сообщения), func(r *Record) bool
+func (w *MemoryWriter) Contains(filters ...interface{}) (found bool) {
+	w.Each(func(r *Record) bool {
+		found = true
+		return false
+	}, filters...)
+	return
+}
+
+// Len возвращает количество сохраненных записей
+func (w *MemoryWriter) Len() int {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	return w.count
+}
+
+// Total возвращает количество записей, полученных writer'ом (включая вытесненные)
+func (w *MemoryWriter) Total() uint64 {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	return w.total
+}
+
+// Reset удаляет сохраненные записи
+func (w *MemoryWriter) Reset() {
+	w.mutex.Lock()
+	for i := range w.records {
+		w.records[i] = Record{}
+	}
+	w.head = 0
+	w.count = 0
+	w.mutex.Unlock()
+}
+
+// ServeHTTP выводит сохраненные записи текстом, от старых к новым
+//	level	- минимальный уровень, например ?level=warn
+//	q		- подстрока сообщения
+//	n		- сколько последних записей вывести
+func (w *MemoryWriter) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	var filters []interface{}
+	if level := req.FormValue("level"); level != "" {
+		minLevel, err := ParseLevel(level)
+		if err != nil {
+			http.Error(rw, err.Error(), http.StatusBadRequest)
+			return
+		}
+		filters = append(filters, minLevel)
+	}
+	if q := req.FormValue("q"); q != "" {
+		filters = append(filters, q)
+	}
+	records := w.Records(filters...)
+	if n, err := strconv.Atoi(req.FormValue("n")); err == nil && n >= 0 && n < len(records) {
+		records = records[len(records)-n:]
+	}
+	var buf []byte
+	for i := range records {
+		buf = TextEncoder{}.Encode(buf, &records[i], false)
+	}
+	rw.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	rw.Write(buf)
+}
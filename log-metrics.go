@@ -0,0 +1,131 @@
+package common
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync/atomic"
+)
+
+// levelCounters счетчики записей по уровням, доступ только через atomic
+type levelCounters [LevelFatal - LevelDebug + 1]uint64
+
+// maxCallerCounts сколько мест вызова с ошибками учитывается, остальные считаются вместе
+const maxCallerCounts = 1000
+
+// callerKey место вызова, с которого выводились ошибки
+type callerKey struct {
+	caller string
+	name   string
+	level  logLevels
+}
+
+// countRecord учитывает запись в счетчиках, l.stateMutex должен быть захвачен
+//	* r - *Record
+func (l *Logger) countRecord(r *Record) {
+	if r.Level < LevelDebug || r.Level > LevelFatal {
+		return
+	}
+	counts := &l.counts
+	if l.component != nil {
+		counts = &l.component.counts
+	}
+	atomic.AddUint64(&counts[r.Level-LevelDebug], 1)
+	if r.Level < LevelError {
+		return
+	}
+	key := callerKey{caller: "other", name: r.Name, level: r.Level}
+	if l.callerCounts == nil {
+		l.callerCounts = make(map[callerKey]uint64, 16)
+	}
+	if r.File != "" {
+		caller := callerKey{caller: fmt.Sprintf("%v:%v", filepath.Base(r.File), r.Line), name: r.Name, level: r.Level}
+		if _, ok := l.callerCounts[caller]; ok || len(l.callerCounts) < maxCallerCounts {
+			key = caller
+		}
+	}
+	l.callerCounts[key]++
+}
+
+// escapeLabel экранирует значение метки для формата Prometheus
+var escapeLabel = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace
+
+// WriteMetrics выводит счетчики логгера в текстовом формате Prometheus:
+// записи по уровням и именованным логгерам, ошибки по местам вызова, выброшенные и подавленные записи,
+// ошибки записи в writer'ы
+//	* w - io.Writer
+func (l *Logger) WriteMetrics(w io.Writer) (err error) {
+	type componentCounts struct {
+		name   string
+		counts *levelCounters
+	}
+	l.stateMutex.Lock()
+	components := []componentCounts{{name: "", counts: &l.counts}}
+	for name, c := range l.components {
+		components = append(components, componentCounts{name: name, counts: &c.counts})
+	}
+	callers := make([]callerKey, 0, len(l.callerCounts))
+	callerCounts := make(map[callerKey]uint64, len(l.callerCounts))
+	for key, count := range l.callerCounts {
+		callers = append(callers, key)
+		callerCounts[key] = count
+	}
+	l.stateMutex.Unlock()
+
+	l.mutex.Lock()
+	writers := make([]string, 0, len(l.writeErrors))
+	writeErrors := make(map[string]uint64, len(l.writeErrors))
+	for writer, count := range l.writeErrors {
+		writers = append(writers, writer)
+		writeErrors[writer] = count
+	}
+	l.mutex.Unlock()
+
+	sort.Slice(components, func(i, j int) bool { return components[i].name < components[j].name })
+	sort.Slice(callers, func(i, j int) bool {
+		if callers[i].name != callers[j].name {
+			return callers[i].name < callers[j].name
+		}
+		if callers[i].caller != callers[j].caller {
+			return callers[i].caller < callers[j].caller
+		}
+		return callers[i].level < callers[j].level
+	})
+	sort.Strings(writers)
+
+	var buf strings.Builder
+	buf.WriteString("# HELP log_records_total Log records by level and logger.\n# TYPE log_records_total counter\n")
+	for _, c := range components {
+		for level := LevelDebug; level <= LevelFatal; level++ {
+			fmt.Fprintf(&buf, "log_records_total{logger=\"%s\",level=\"%v\"} %d\n",
+				escapeLabel(c.name), level, atomic.LoadUint64(&c.counts[level-LevelDebug]))
+		}
+	}
+	buf.WriteString("# HELP log_errors_by_caller_total Error and fatal log records by call site.\n# TYPE log_errors_by_caller_total counter\n")
+	for _, key := range callers {
+		fmt.Fprintf(&buf, "log_errors_by_caller_total{logger=\"%s\",caller=\"%s\",level=\"%v\"} %d\n",
+			escapeLabel(key.name), escapeLabel(key.caller), key.level, callerCounts[key])
+	}
+	buf.WriteString("# HELP log_dropped_records_total Log records dropped because of full queues.\n# TYPE log_dropped_records_total counter\n")
+	fmt.Fprintf(&buf, "log_dropped_records_total{queue=\"async\"} %d\n", l.Dropped())
+	fmt.Fprintf(&buf, "log_dropped_records_total{queue=\"hooks\"} %d\n", l.HooksDropped())
+	buf.WriteString("# HELP log_suppressed_records_total Log records suppressed by dedupe and rate limit.\n# TYPE log_suppressed_records_total counter\n")
+	fmt.Fprintf(&buf, "log_suppressed_records_total %d\n", l.Suppressed())
+	buf.WriteString("# HELP log_write_errors_total Failed writes to log writers.\n# TYPE log_write_errors_total counter\n")
+	for _, writer := range writers {
+		fmt.Fprintf(&buf, "log_write_errors_total{writer=\"%s\"} %d\n", escapeLabel(writer), writeErrors[writer])
+	}
+	_, err = io.WriteString(w, buf.String())
+	return
+}
+
+// MetricsHandler возвращает http.Handler, выводящий счетчики логгера в формате Prometheus (см. WriteMetrics)
+func (l *Logger) MetricsHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		l.WriteMetrics(w)
+	})
+}
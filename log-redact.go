@@ -0,0 +1,378 @@
+package common
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+	"unsafe"
+)
+
+// RedactMask чем заменяются секреты
+const RedactMask = "***"
+
+// Шаблоны секретов для Redact. Если в шаблоне есть группы, маскируются только они
+var (
+	// RedactTelegramToken токен бота Telegram, в том числе в URL /bot<token>/
+	RedactTelegramToken = regexp.MustCompile(`\d{5,12}:[A-Za-z0-9_-]{30,}`)
+	// RedactPasswordParam значения password=, passwd=, secret=, token= и т.п. в URL и строках конфигурации
+	RedactPasswordParam = regexp.MustCompile(`(?i)(?:password|passwd|pwd|secret|token|api_?key)=([^&\s"',;]+)`)
+	// RedactBearer токен в заголовке "Authorization: Bearer ..."
+	RedactBearer = regexp.MustCompile(`(?i)bearer\s+([A-Za-z0-9._~+/=-]+)`)
+	// RedactDefaults все шаблоны выше: Log.Redact(common.RedactDefaults...)
+	RedactDefaults = []interface{}{RedactTelegramToken, RedactPasswordParam, RedactBearer}
+)
+
+// logRedactor секреты и шаблоны логгера, не изменяется после создания
+type logRedactor struct {
+	secrets  []string
+	patterns []*regexp.Regexp
+	replacer *strings.Replacer
+}
+
+// Redact добавляет секреты, которые маскируются в сообщениях, значениях полей и ошибках до вывода в writer'ы и hook'и
+//	* secrets - string (значение секрета, например токен бота), *regexp.Regexp (шаблон, см. RedactDefaults)
+func (l *Logger) Redact(secrets ...interface{}) {
+	l.stateMutex.Lock()
+	defer l.stateMutex.Unlock()
+	redactor := &logRedactor{}
+	if l.redactor != nil {
+		redactor.secrets = append(redactor.secrets, l.redactor.secrets...)
+		redactor.patterns = append(redactor.patterns, l.redactor.patterns...)
+	}
+	for _, secret := range secrets {
+		switch secret := secret.(type) {
+		case string:
+			if secret != "" {
+				redactor.secrets = append(redactor.secrets, secret)
+			}
+		case *regexp.Regexp:
+			redactor.patterns = append(redactor.patterns, secret)
+		}
+	}
+	if len(redactor.secrets) > 0 {
+		pairs := make([]string, 0, len(redactor.secrets)*2)
+		for _, secret := range redactor.secrets {
+			pairs = append(pairs, secret, RedactMask)
+		}
+		redactor.replacer = strings.NewReplacer(pairs...)
+	}
+	l.redactor = redactor
+}
+
+// redactString маскирует секреты в строке
+func (redactor *logRedactor) redactString(str string) string {
+	if redactor.replacer != nil {
+		str = redactor.replacer.Replace(str)
+	}
+	for _, pattern := range redactor.patterns {
+		str = redactPattern(pattern, str)
+	}
+	return str
+}
+
+// redactPattern маскирует совпадения шаблона, а если в нем есть группы - только группы
+func redactPattern(pattern *regexp.Regexp, str string) string {
+	matches := pattern.FindAllStringSubmatchIndex(str, -1)
+	if matches == nil {
+		return str
+	}
+	var buf strings.Builder
+	last := 0
+	for _, match := range matches {
+		groups := match[2:]
+		if len(groups) == 0 {
+			groups = match[:2]
+		}
+		for i := 0; i+1 < len(groups); i += 2 {
+			if groups[i] < last {
+				continue
+			}
+			buf.WriteString(str[last:groups[i]])
+			buf.WriteString(RedactMask)
+			last = groups[i+1]
+		}
+	}
+	buf.WriteString(str[last:])
+	return buf.String()
+}
+
+// redact маскирует секреты в сообщении, полях и ошибке записи
+// Поля копируются, чтобы не менять поля логгера, созданные With. Значение поля с секретом
+// заменяется строкой с замаскированным секретом, ошибка - redactedError
+func (redactor *logRedactor) redact(r *Record) {
+	r.Message = redactor.redactString(r.Message)
+	var fields []Field
+	for idx, field := range r.Fields {
+		value, ok := renderValue(field.Value)
+		if !ok {
+			continue
+		}
+		redacted := redactor.redactString(value)
+		if redacted == value {
+			continue
+		}
+		if fields == nil {
+			fields = append([]Field(nil), r.Fields...)
+		}
+		fields[idx].Value = redacted
+	}
+	if fields != nil {
+		r.Fields = fields
+	}
+	if r.Err != nil {
+		message := r.Err.Error()
+		if redacted := redactor.redactString(message); redacted != message {
+			r.Err = &redactedError{err: r.Err, message: redacted}
+		}
+	}
+}
+
+// renderValue возвращает значение поля так, как его выведет fmt (в том числе через Error и String),
+// ok = false для значений, в которых не может быть секретов (числа, логические значения, nil)
+func renderValue(value interface{}) (str string, ok bool) {
+	switch v := value.(type) {
+	case nil, bool, int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64,
+		float32, float64, time.Duration, time.Time:
+		return "", false
+	case string:
+		return v, true
+	}
+	return fmt.Sprint(value), true
+}
+
+// redactedError ошибка записи с замаскированными секретами в тексте
+// errors.Is, errors.As и стек вызовов (см. SetErrorStackTraces) работают с исходной ошибкой
+type redactedError struct {
+	err     error
+	message string
+}
+
+func (e *redactedError) Error() string {
+	return e.message
+}
+
+func (e *redactedError) Unwrap() error {
+	return e.err
+}
+
+// secretTypes кэш: есть ли в значениях типа поля с тегом log:"secret"
+var secretTypes sync.Map // reflect.Type -> bool
+
+// maxMaskDepth значения с секретами глубже этого (например, в циклических списках) заменяются нулевыми
+const maxMaskDepth = 10
+
+// hasSecrets проверяет, что в значениях типа t (в том числе во вложенных структурах, указателях,
+// срезах, массивах, значениях отображений и полях-интерфейсах) могут быть поля с тегом log:"secret"
+func hasSecrets(t reflect.Type) bool {
+	if cached, ok := secretTypes.Load(t); ok {
+		return cached.(bool)
+	}
+	// Кэшируется только результат для t: промежуточные результаты для рекурсивных типов
+	// считаются с допущением, что тип в процессе обхода секретов не содержит
+	result := typeHasSecrets(t, make(map[reflect.Type]bool, 4))
+	cached, _ := secretTypes.LoadOrStore(t, result)
+	return cached.(bool)
+}
+
+func typeHasSecrets(t reflect.Type, visiting map[reflect.Type]bool) bool {
+	if visiting[t] {
+		return false
+	}
+	switch t.Kind() {
+	case reflect.Interface:
+		// Секреты зависят от значения, проверяются при обходе
+		return true
+	case reflect.Ptr, reflect.Slice, reflect.Array, reflect.Map:
+		visiting[t] = true
+		defer delete(visiting, t)
+		return typeHasSecrets(t.Elem(), visiting)
+	case reflect.Struct:
+		visiting[t] = true
+		defer delete(visiting, t)
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			if field.Tag.Get("log") == "secret" || typeHasSecrets(field.Type, visiting) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// maskSecrets возвращает копию значения, в которой поля с тегом log:"secret" (в том числе
+// неэкспортированные и вложенные в структуры, указатели, срезы, массивы, значения отображений и интерфейсы)
+// замаскированы: строки заменяются на RedactMask, остальное - на нулевое значение
+// Исходное значение не изменяется, masked = false, если маскировать нечего
+//	* value - interface{}
+func maskSecrets(value interface{}) (result interface{}, masked bool) {
+	v := reflect.ValueOf(value)
+	if !v.IsValid() || !valueHasSecrets(v, 0) {
+		return value, false
+	}
+	return maskValue(v, 0).Interface(), true
+}
+
+// formatsItself проверяет, что fmt выводит значение через Error или String, а не по полям
+func formatsItself(v reflect.Value) bool {
+	if !v.CanInterface() {
+		return false
+	}
+	switch v.Interface().(type) {
+	case error, fmt.Stringer, fmt.Formatter:
+		return true
+	}
+	return false
+}
+
+// valueHasSecrets проверяет, что в v есть непустые поля с тегом log:"secret", которые выведет fmt
+func valueHasSecrets(v reflect.Value, depth int) bool {
+	if !hasSecrets(v.Type()) || formatsItself(v) {
+		return false
+	}
+	if depth > maxMaskDepth {
+		return true
+	}
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		return !v.IsNil() && valueHasSecrets(v.Elem(), depth+1)
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			if valueHasSecrets(v.Index(i), depth+1) {
+				return true
+			}
+		}
+	case reflect.Map:
+		for iter := v.MapRange(); iter.Next(); {
+			if valueHasSecrets(iter.Value(), depth+1) {
+				return true
+			}
+		}
+	case reflect.Struct:
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			if t.Field(i).Tag.Get("log") == "secret" {
+				if !v.Field(i).IsZero() {
+					return true
+				}
+			} else if valueHasSecrets(exposeField(v, i), depth+1) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// exposeField возвращает поле i структуры v, неэкспортированное поле - через указатель на него,
+// чтобы его можно было прочитать (и изменить, если v - изменяемая копия)
+func exposeField(v reflect.Value, i int) reflect.Value {
+	field := v.Field(i)
+	if v.Type().Field(i).PkgPath == "" || !field.CanAddr() {
+		return field
+	}
+	return reflect.NewAt(field.Type(), unsafe.Pointer(field.UnsafeAddr())).Elem()
+}
+
+// maskValue возвращает копию v с замаскированными секретами, части без секретов не копируются
+func maskValue(v reflect.Value, depth int) reflect.Value {
+	t := v.Type()
+	if !valueHasSecrets(v, depth) {
+		return v
+	}
+	if depth > maxMaskDepth {
+		return reflect.Zero(t)
+	}
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() {
+			return v
+		}
+		copied := reflect.New(t.Elem())
+		copied.Elem().Set(maskValue(v.Elem(), depth+1))
+		return copied
+	case reflect.Interface:
+		if v.IsNil() {
+			return v
+		}
+		copied := reflect.New(t).Elem()
+		copied.Set(maskValue(v.Elem(), depth+1))
+		return copied
+	case reflect.Slice:
+		if v.IsNil() {
+			return v
+		}
+		copied := reflect.MakeSlice(t, v.Len(), v.Len())
+		for i := 0; i < v.Len(); i++ {
+			copied.Index(i).Set(maskValue(v.Index(i), depth+1))
+		}
+		return copied
+	case reflect.Array:
+		copied := reflect.New(t).Elem()
+		for i := 0; i < v.Len(); i++ {
+			copied.Index(i).Set(maskValue(v.Index(i), depth+1))
+		}
+		return copied
+	case reflect.Map:
+		if v.IsNil() {
+			return v
+		}
+		copied := reflect.MakeMapWithSize(t, v.Len())
+		for iter := v.MapRange(); iter.Next(); {
+			copied.SetMapIndex(iter.Key(), maskValue(iter.Value(), depth+1))
+		}
+		return copied
+	case reflect.Struct:
+		copied := reflect.New(t).Elem()
+		copied.Set(v)
+		for i := 0; i < t.NumField(); i++ {
+			field := exposeField(copied, i)
+			if t.Field(i).Tag.Get("log") != "secret" {
+				field.Set(maskValue(field, depth+1))
+			} else if field.Kind() == reflect.String {
+				if field.Len() > 0 {
+					field.SetString(RedactMask)
+				}
+			} else {
+				field.Set(reflect.Zero(field.Type()))
+			}
+		}
+		return copied
+	}
+	return v
+}
+
+// maskArgs маскирует поля с тегом log:"secret" в аргументах сообщения, s не изменяется
+func maskArgs(s []interface{}) []interface{} {
+	var result []interface{}
+	for idx, arg := range s {
+		if masked, ok := maskSecrets(arg); ok {
+			if result == nil {
+				result = append([]interface{}(nil), s...)
+			}
+			result[idx] = masked
+		}
+	}
+	if result == nil {
+		return s
+	}
+	return result
+}
+
+// maskFields маскирует поля с тегом log:"secret" в значениях полей, fields не изменяется
+func maskFields(fields []Field) []Field {
+	var result []Field
+	for idx, field := range fields {
+		if masked, ok := maskSecrets(field.Value); ok {
+			if result == nil {
+				result = append([]Field(nil), fields...)
+			}
+			result[idx].Value = masked
+		}
+	}
+	if result == nil {
+		return fields
+	}
+	return result
+}
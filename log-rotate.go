@@ -0,0 +1,323 @@
+package common
+
+import (
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Параметры NewRotatingFile и SetFileWriter
+type (
+	// RotateMaxSize ротация при достижении размера файла (в байтах)
+	RotateMaxSize int64
+	// RotateDaily ротация при смене дня
+	RotateDaily bool
+	// RotateMaxBackups сколько ротированных файлов хранить (0 - все)
+	RotateMaxBackups int
+	// RotateMaxAge удалять ротированные файлы старше (0 - не удалять)
+	RotateMaxAge time.Duration
+	// RotateCompress сжимать ротированные файлы gzip'ом
+	RotateCompress bool
+)
+
+const rotateTimeFormat = "2006-01-02T15-04-05.000"
+
+// rotateRetryDelay через сколько повторять ротацию, если она не удалась
+const rotateRetryDelay = time.Minute
+
+// RotatingFile writer в файл с ротацией по размеру и/или по дням
+type RotatingFile struct {
+	fileName   string
+	maxSize    int64
+	daily      bool
+	maxBackups int
+	maxAge     time.Duration
+	compress   bool
+
+	mutex    sync.Mutex
+	file     *os.File
+	size     int64
+	openDate int
+	closed   bool
+	// retryAt раньше этого времени ротация не повторяется после ошибки
+	retryAt time.Time
+	// фоновое сжатие и удаление старых файлов, cleanupMutex выполняет их по очереди
+	wg           sync.WaitGroup
+	cleanupMutex sync.Mutex
+}
+
+// NewRotatingFile открывает (создает) файл для дописывания с ротацией
+//	* fileName	- string
+//	* params	- RotateMaxSize, RotateDaily, RotateMaxBackups, RotateMaxAge, RotateCompress
+// Без параметров файл никогда не ротируется
+func NewRotatingFile(fileName string, params ...interface{}) (f *RotatingFile, err error) {
+	f = &RotatingFile{fileName: fileName}
+	for _, param := range params {
+		switch param := param.(type) {
+		case RotateMaxSize:
+			f.maxSize = int64(param)
+		case RotateDaily:
+			f.daily = bool(param)
+		case RotateMaxBackups:
+			f.maxBackups = int(param)
+		case RotateMaxAge:
+			f.maxAge = time.Duration(param)
+		case RotateCompress:
+			f.compress = bool(param)
+		}
+	}
+	if err = f.open(); err != nil {
+		return nil, err
+	}
+	return
+}
+
+func dateOf(t time.Time) int {
+	year, month, day := t.Date()
+	return year*10000 + int(month)*100 + day
+}
+
+// open открывает файл, f.mutex должен быть захвачен (или f еще не опубликован)
+// Если открыть не получилось, f.file = nil и открыть файл пробует следующая запись
+func (f *RotatingFile) open() error {
+	f.file = nil
+	file, err := os.OpenFile(f.fileName, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	f.file = file
+	f.size = info.Size()
+	f.openDate = dateOf(info.ModTime())
+	if f.size == 0 {
+		f.openDate = dateOf(time.Now())
+	}
+	return nil
+}
+
+// Write дописывает p в файл, при необходимости предварительно ротируя его
+// Если ротировать не получилось, p все равно дописывается в текущий файл, а вместе с n возвращается
+// ошибка ротации; следующая попытка ротации - не раньше чем через rotateRetryDelay
+func (f *RotatingFile) Write(p []byte) (n int, err error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if f.closed {
+		return 0, os.ErrClosed
+	}
+	if f.file == nil {
+		if err = f.open(); err != nil {
+			return
+		}
+	}
+	now := time.Now()
+	if f.size > 0 && (f.maxSize > 0 && f.size+int64(len(p)) > f.maxSize ||
+		f.daily && dateOf(now) != f.openDate) && !now.Before(f.retryAt) {
+		if err = f.rotate(); err != nil {
+			f.retryAt = now.Add(rotateRetryDelay)
+			if f.file == nil {
+				return
+			}
+		}
+	}
+	var writeErr error
+	n, writeErr = f.file.Write(p)
+	f.size += int64(n)
+	if writeErr != nil {
+		err = writeErr
+	}
+	return
+}
+
+// Rotate принудительно ротирует файл
+func (f *RotatingFile) Rotate() error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if f.closed {
+		return os.ErrClosed
+	}
+	if f.file == nil {
+		if err := f.open(); err != nil {
+			return err
+		}
+	}
+	return f.rotate()
+}
+
+// rotate переименовывает файл в ротированный и открывает новый, f.mutex должен быть захвачен
+// Если переименовать не получилось, заново открывается тот же файл
+func (f *RotatingFile) rotate() error {
+	if err := f.file.Close(); err != nil {
+		f.open()
+		return err
+	}
+	backup := f.backupName()
+	if err := os.Rename(f.fileName, backup); err != nil {
+		// Не получилось переименовать - продолжаем писать в тот же файл
+		if openErr := f.open(); openErr != nil {
+			return openErr
+		}
+		return err
+	}
+	if err := f.open(); err != nil {
+		return err
+	}
+	f.wg.Add(1)
+	go func() {
+		defer f.wg.Done()
+		f.cleanupMutex.Lock()
+		defer f.cleanupMutex.Unlock()
+		if f.compress {
+			compressFile(backup)
+		}
+		f.removeOld()
+	}()
+	return nil
+}
+
+// backupName возвращает имя для ротированного файла, которого еще нет (в том числе сжатого):
+// при совпадении время в имени сдвигается на миллисекунду
+func (f *RotatingFile) backupName() string {
+	ext := filepath.Ext(f.fileName)
+	base := strings.TrimSuffix(f.fileName, ext) + "-"
+	stamp := time.Now()
+	for {
+		backup := base + stamp.Format(rotateTimeFormat) + ext
+		if !fileExists(backup) && !fileExists(backup+".gz") {
+			return backup
+		}
+		stamp = stamp.Add(time.Millisecond)
+	}
+}
+
+// fileExists проверяет, что файл (или каталог) существует
+func fileExists(fileName string) bool {
+	_, err := os.Lstat(fileName)
+	return !os.IsNotExist(err)
+}
+
+// compressFile сжимает файл в fileName.gz и удаляет исходный
+func compressFile(fileName string) {
+	src, err := os.Open(fileName)
+	if err != nil {
+		return
+	}
+	defer src.Close()
+	dst, err := os.OpenFile(fileName+".gz", os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+	if err != nil {
+		return
+	}
+	gz := gzip.NewWriter(dst)
+	_, err = io.Copy(gz, src)
+	if closeErr := gz.Close(); err == nil {
+		err = closeErr
+	}
+	if closeErr := dst.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(fileName + ".gz")
+		return
+	}
+	os.Remove(fileName)
+}
+
+// Backups возвращает ротированные файлы, от новых к старым
+// Если файл еще сжимается (есть и x.log, и x.log.gz), возвращается x.log
+func (f *RotatingFile) Backups() (backups []string) {
+	ext := filepath.Ext(f.fileName)
+	prefix := filepath.Base(strings.TrimSuffix(f.fileName, ext)) + "-"
+	dir := filepath.Dir(f.fileName)
+	files, err := filepath.Glob(filepath.Join(dir, "*"))
+	if err != nil {
+		return
+	}
+	seen := make(map[string]int, len(files))
+	for _, file := range files {
+		name := strings.TrimSuffix(filepath.Base(file), ".gz")
+		if !strings.HasPrefix(name, prefix) || !strings.HasSuffix(name, ext) {
+			continue
+		}
+		stamp := strings.TrimSuffix(strings.TrimPrefix(name, prefix), ext)
+		if _, err := time.ParseInLocation(rotateTimeFormat, stamp, time.Local); err != nil {
+			continue
+		}
+		if idx, ok := seen[name]; ok {
+			if !strings.HasSuffix(file, ".gz") {
+				backups[idx] = file
+			}
+			continue
+		}
+		seen[name] = len(backups)
+		backups = append(backups, file)
+	}
+	sort.Sort(sort.Reverse(sort.StringSlice(backups)))
+	return
+}
+
+// removeOld удаляет лишние и устаревшие ротированные файлы
+func (f *RotatingFile) removeOld() {
+	if f.maxBackups <= 0 && f.maxAge <= 0 {
+		return
+	}
+	for idx, file := range f.Backups() {
+		if f.maxBackups > 0 && idx >= f.maxBackups {
+			removeBackup(file)
+			continue
+		}
+		if f.maxAge > 0 {
+			if info, err := os.Stat(file); err == nil && time.Since(info.ModTime()) > f.maxAge {
+				removeBackup(file)
+			}
+		}
+	}
+}
+
+// removeBackup удаляет ротированный файл вместе с его сжатой копией
+func removeBackup(file string) {
+	file = strings.TrimSuffix(file, ".gz")
+	os.Remove(file)
+	os.Remove(file + ".gz")
+}
+
+// Reopen закрывает и заново открывает файл по тому же пути
+// (например, после того как внешний logrotate переименовал его)
+func (f *RotatingFile) Reopen() error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if f.closed {
+		return os.ErrClosed
+	}
+	// Если открыть не получилось - продолжаем писать в старый файл
+	prev := f.file
+	if err := f.open(); err != nil {
+		f.file = prev
+		return err
+	}
+	if prev == nil {
+		return nil
+	}
+	return prev.Close()
+}
+
+// Close закрывает файл и дожидается окончания фонового сжатия
+func (f *RotatingFile) Close() (err error) {
+	f.mutex.Lock()
+	if !f.closed {
+		f.closed = true
+		if f.file != nil {
+			err = f.file.Close()
+		}
+	}
+	f.mutex.Unlock()
+	f.wg.Wait()
+	return
+}
@@ -0,0 +1,157 @@
+package common
+
+import (
+	"compress/gzip"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRotatingFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rotate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	fileName := filepath.Join(dir, "bot.log")
+	l, _ := newTestLogger(LevelDebug)
+	if err = l.SetFileWriter(fileName, RotateMaxSize(100), RotateMaxBackups(2), RotateCompress(true)); err != nil {
+		t.Fatal(err)
+	}
+	f := l.GetWriter().(*RotatingFile)
+	for i := 0; i < 10; i++ {
+		l.Info(strings.Repeat("x", 40))
+		f.wg.Wait()
+	}
+	if err = l.Close(); err != nil {
+		t.Fatal(err)
+	}
+	backups := f.Backups()
+	if len(backups) != 2 {
+		t.Fatalf("expected 2 backups, got %v", backups)
+	}
+	for _, backup := range backups {
+		if !strings.HasSuffix(backup, ".log.gz") {
+			t.Fatalf("backup %q is not compressed", backup)
+		}
+		file, err := os.Open(backup)
+		if err != nil {
+			t.Fatal(err)
+		}
+		gz, err := gzip.NewReader(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := ioutil.ReadAll(gz)
+		file.Close()
+		if err != nil || !strings.Contains(string(data), strings.Repeat("x", 40)) {
+			t.Fatalf("unexpected backup content %q: %v", data, err)
+		}
+	}
+	if _, err = f.Write([]byte("after close\n")); err != os.ErrClosed {
+		t.Fatalf("write after close: %v", err)
+	}
+}
+
+func TestRotatingFileCleanup(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rotate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	fileName := filepath.Join(dir, "bot.log")
+	f, err := NewRotatingFile(fileName, RotateMaxBackups(3), RotateCompress(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Ротации подряд, не дожидаясь сжатия предыдущих файлов
+	for i := 0; i < 8; i++ {
+		f.Write([]byte(strings.Repeat("x", 64*1024) + "\n"))
+		if err = f.Rotate(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err = f.Close(); err != nil {
+		t.Fatal(err)
+	}
+	backups := f.Backups()
+	files, _ := filepath.Glob(filepath.Join(dir, "bot-*"))
+	if len(backups) != 3 || len(files) != 3 {
+		t.Fatalf("expected 3 backups, got %v (files %v)", backups, files)
+	}
+	for _, backup := range backups {
+		if !strings.HasSuffix(backup, ".log.gz") {
+			t.Fatalf("backup %q is not compressed", backup)
+		}
+	}
+}
+
+func TestRotatingFileErrors(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rotate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	fileName := filepath.Join(dir, "bot.log")
+	f, err := NewRotatingFile(fileName, RotateMaxSize(10))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	// Ротации в одну миллисекунду не затирают друг друга
+	for i := 0; i < 5; i++ {
+		f.Write([]byte("line\n"))
+		if err = f.Rotate(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if backups := f.Backups(); len(backups) != 5 {
+		t.Fatalf("expected 5 backups, got %v", backups)
+	}
+	// Файл удален снаружи: переименовать его не получится, но запись не теряется
+	f.Write([]byte("before\n"))
+	if err = os.Remove(fileName); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := f.Write([]byte("after rotate error\n")); err == nil || n != 19 {
+		t.Fatalf("expected rotation error and full write, got %v, %v", n, err)
+	}
+	if n, err := f.Write([]byte("next\n")); err != nil || n != 5 {
+		t.Fatalf("unexpected write result %v, %v", n, err)
+	}
+	data, err := ioutil.ReadFile(fileName)
+	if err != nil || string(data) != "after rotate error\nnext\n" {
+		t.Fatalf("unexpected file content %q: %v", data, err)
+	}
+	if backups := f.Backups(); len(backups) != 5 {
+		t.Fatalf("expected 5 backups, got %v", backups)
+	}
+}
+
+func TestLoggerReopen(t *testing.T) {
+	dir, err := ioutil.TempDir("", "reopen")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	fileName := filepath.Join(dir, "bot.log")
+	l, _ := newTestLogger(LevelDebug)
+	if err = l.SetFileWriter(fileName); err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	l.Info("before")
+	if err = os.Rename(fileName, fileName+".1"); err != nil {
+		t.Fatal(err)
+	}
+	if err = l.Reopen(); err != nil {
+		t.Fatal(err)
+	}
+	l.Info("after")
+	data, err := ioutil.ReadFile(fileName)
+	if err != nil || strings.Contains(string(data), "before") || !strings.Contains(string(data), "after") {
+		t.Fatalf("unexpected reopened file content %q: %v", data, err)
+	}
+}
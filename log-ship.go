@@ -0,0 +1,480 @@
+package common
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ShipFormat формат отправки записей HTTPShipper
+type ShipFormat int
+
+const (
+	// ShipLoki Loki push API (POST /loki/api/v1/push)
+	ShipLoki ShipFormat = iota
+	// ShipElasticsearch Elasticsearch bulk API (POST /_bulk)
+	ShipElasticsearch
+)
+
+// Параметры NewHTTPShipper
+type (
+	// ShipBatchSize сколько записей отправлять одним запросом (по умолчанию 500)
+	ShipBatchSize int
+	// ShipFlushInterval как часто отправлять неполную пачку (по умолчанию 2 секунды)
+	ShipFlushInterval time.Duration
+	// ShipLabels поля записи, которые становятся метками потока Loki (кроме level, logger и app)
+	ShipLabels []string
+	// ShipIndex индекс Elasticsearch (по умолчанию "logs")
+	ShipIndex string
+	// ShipRetries сколько раз повторять неудачный запрос (по умолчанию 5)
+	ShipRetries int
+	// ShipBackoff пауза перед первым повтором, дальше удваивается (по умолчанию 500 мс)
+	ShipBackoff time.Duration
+	// ShipSpoolDir каталог, куда сохраняются пачки, которые не удалось отправить
+	// Они отправляются повторно после следующей удачной отправки и при запуске
+	ShipSpoolDir string
+	// ShipHeader заголовки запроса (авторизация, X-Scope-OrgID и т.п.)
+	ShipHeader http.Header
+	// ShipExitTimeout сколько отправлять оставшиеся записи при Exit (по умолчанию 5 секунд),
+	// то, что не успело отправиться, сохраняется в ShipSpoolDir
+	ShipExitTimeout time.Duration
+)
+
+const (
+	// shipMaxBacklog во сколько раз больше ShipBatchSize записей может ждать отправки
+	shipMaxBacklog = 10
+	// shipMaxSpoolFiles сколько пачек хранится в ShipSpoolDir, самые старые удаляются
+	shipMaxSpoolFiles = 1000
+	// shipMaxBackoff максимальная пауза между повторами
+	shipMaxBackoff = 30 * time.Second
+)
+
+// HTTPShipper writer, отправляющий записи пачками по HTTP в Loki или Elasticsearch
+// Тело запроса сжимается gzip, при ошибке запрос повторяется, а затем пачка сохраняется в ShipSpoolDir.
+// При Exit оставшиеся записи отправляются:
+// shipper, err := common.NewHTTPShipper(common.ShipLoki, "http://loki:3100/loki/api/v1/push", common.ShipLabels{"bot"})
+// common.Log.AddWriter(shipper)
+type HTTPShipper struct {
+	format    ShipFormat
+	url       string
+	safeURL   string // адрес без пароля и параметров запроса для сообщений об ошибках
+	batchSize int
+	interval  time.Duration
+	labels    []string
+	index     string
+	retries   int
+	backoff   time.Duration
+	timeout   time.Duration
+	spoolDir  string
+	header    http.Header
+	client    *http.Client
+	logger    *Logger
+	appName   string
+
+	mutex   sync.Mutex
+	records []Record
+	dropped uint64
+	closed  bool
+
+	kick      chan struct{}
+	exitWait  chan WaitChanResult
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// NewHTTPShipper создает writer, отправляющий записи по HTTP
+//	* format	- ShipFormat
+//	* url		- адрес push API Loki или bulk API Elasticsearch
+//	* params	- ShipBatchSize, ShipFlushInterval, ShipLabels, ShipIndex, ShipRetries, ShipBackoff,
+//				  ShipSpoolDir, ShipHeader, ShipExitTimeout, *http.Client,
+//				  *Logger (в запасной writer которого выводятся ошибки отправки, по умолчанию Log)
+func NewHTTPShipper(format ShipFormat, url string, params ...interface{}) (s *HTTPShipper, err error) {
+	s = &HTTPShipper{
+		format:    format,
+		url:       url,
+		safeURL:   safeURL(url),
+		batchSize: 500,
+		interval:  2 * time.Second,
+		index:     "logs",
+		retries:   5,
+		backoff:   500 * time.Millisecond,
+		timeout:   5 * time.Second,
+		client:    &http.Client{Timeout: 30 * time.Second},
+		logger:    Log,
+		appName:   defaultAppName(),
+		kick:      make(chan struct{}, 1),
+		done:      make(chan struct{}),
+	}
+	for _, param := range params {
+		switch param := param.(type) {
+		case ShipBatchSize:
+			s.batchSize = int(param)
+		case ShipFlushInterval:
+			s.interval = time.Duration(param)
+		case ShipLabels:
+			s.labels = param
+		case ShipIndex:
+			s.index = string(param)
+		case ShipRetries:
+			s.retries = int(param)
+		case ShipBackoff:
+			s.backoff = time.Duration(param)
+		case ShipSpoolDir:
+			s.spoolDir = string(param)
+		case ShipHeader:
+			s.header = http.Header(param)
+		case ShipExitTimeout:
+			s.timeout = time.Duration(param)
+		case *http.Client:
+			s.client = param
+		case *Logger:
+			s.logger = param
+		}
+	}
+	if s.batchSize < 1 {
+		s.batchSize = 1
+	}
+	if s.spoolDir != "" {
+		if err = os.MkdirAll(s.spoolDir, 0755); err != nil {
+			return nil, err
+		}
+	}
+	s.exitWait = ExitWaitChans.Add()
+	go s.run()
+	return
+}
+
+// WriteRecord добавляет копию записи в пачку
+// Если отправка не успевает, новые записи выбрасываются (см. Dropped)
+func (s *HTTPShipper) WriteRecord(r *Record) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.closed {
+		return os.ErrClosed
+	}
+	if len(s.records) >= s.batchSize*shipMaxBacklog {
+		s.dropped++
+		return nil
+	}
+	record := *r
+	record.Fields = append([]Field(nil), r.Fields...)
+	record.Stack = append([]StackFrame(nil), r.Stack...)
+	s.records = append(s.records, record)
+	if len(s.records) >= s.batchSize {
+		select {
+		case s.kick <- struct{}{}:
+		default:
+		}
+	}
+	return nil
+}
+
+// Write добавляет p в пачку как сообщение уровня LevelInfo
+func (s *HTTPShipper) Write(p []byte) (int, error) {
+	if err := s.WriteRecord(&Record{Time: time.Now(), Level: LevelInfo, Message: strings.TrimSuffix(string(p), "\n")}); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// Dropped возвращает количество записей, выброшенных из-за того, что отправка не успевает
+func (s *HTTPShipper) Dropped() uint64 {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.dropped
+}
+
+// run отправляет пачки по заполнению и по таймеру, при запуске - сохраненные в ShipSpoolDir
+func (s *HTTPShipper) run() {
+	defer close(s.done)
+	s.resendSpool()
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.kick:
+			s.flush(false)
+		case <-ticker.C:
+			s.flush(false)
+		case done, ok := <-s.exitWait:
+			s.mutex.Lock()
+			s.closed = true
+			s.mutex.Unlock()
+			s.flush(true)
+			if ok {
+				done.Done()
+			}
+			return
+		}
+	}
+}
+
+// flush отправляет все накопленные записи пачками по ShipBatchSize
+//	* final - при Exit: без повторов и не дольше ShipExitTimeout, неотправленное сразу сохраняется в ShipSpoolDir
+func (s *HTTPShipper) flush(final bool) {
+	ctx := context.Background()
+	if final {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.timeout)
+		defer cancel()
+	}
+	for {
+		s.mutex.Lock()
+		n := len(s.records)
+		if n > s.batchSize {
+			n = s.batchSize
+		}
+		batch := s.records[:n:n]
+		s.records = s.records[n:]
+		if len(s.records) == 0 {
+			s.records = nil
+		}
+		s.mutex.Unlock()
+		if n == 0 {
+			return
+		}
+		body, err := s.encode(batch)
+		if err == nil {
+			err = ctx.Err()
+		}
+		if err == nil {
+			err = s.ship(ctx, body, final)
+		}
+		if err != nil {
+			s.logger.reportError("log shipping error: %v", err)
+			s.spool(body)
+		} else if !final {
+			s.resendSpool()
+		}
+	}
+}
+
+// encode возвращает сжатое тело запроса для пачки записей
+func (s *HTTPShipper) encode(batch []Record) ([]byte, error) {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	var err error
+	if s.format == ShipElasticsearch {
+		err = s.encodeBulk(gz, batch)
+	} else {
+		err = s.encodeLoki(gz, batch)
+	}
+	if closeErr := gz.Close(); err == nil {
+		err = closeErr
+	}
+	return buf.Bytes(), err
+}
+
+// lokiStream поток Loki: метки и строки [время в наносекундах, строка]
+type lokiStream struct {
+	Stream map[string]string `json:"stream"`
+	Values [][2]string       `json:"values"`
+}
+
+// encodeLoki пишет пачку в формате push API Loki, строки - в logfmt, записи группируются по меткам
+func (s *HTTPShipper) encodeLoki(w io.Writer, batch []Record) error {
+	streams := make(map[string]*lokiStream, 4)
+	var keys []string
+	var line []byte
+	for i := range batch {
+		r := &batch[i]
+		labels := map[string]string{"app": s.appName, "level": r.Level.String()}
+		if r.Name != "" {
+			labels["logger"] = r.Name
+		}
+		for _, field := range r.Fields {
+			for _, label := range s.labels {
+				if field.Key == label {
+					var value []byte
+					appendRawValue(&value, field.Value)
+					labels[label] = string(value)
+				}
+			}
+		}
+		key := lokiStreamKey(labels)
+		stream, ok := streams[key]
+		if !ok {
+			stream = &lokiStream{Stream: labels}
+			streams[key] = stream
+			keys = append(keys, key)
+		}
+		line = LogfmtEncoder{}.Encode(line[:0], r, false)
+		stream.Values = append(stream.Values, [2]string{
+			strconv.FormatInt(r.Time.UnixNano(), 10),
+			strings.TrimSuffix(string(line), "\n"),
+		})
+	}
+	request := struct {
+		Streams []*lokiStream `json:"streams"`
+	}{}
+	for _, key := range keys {
+		request.Streams = append(request.Streams, streams[key])
+	}
+	return json.NewEncoder(w).Encode(&request)
+}
+
+// lokiStreamKey возвращает ключ набора меток
+func lokiStreamKey(labels map[string]string) string {
+	names := make([]string, 0, len(labels))
+	for name := range labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var key strings.Builder
+	for _, name := range names {
+		key.WriteString(name)
+		key.WriteByte('=')
+		key.WriteString(strconv.Quote(labels[name]))
+		key.WriteByte(',')
+	}
+	return key.String()
+}
+
+// encodeBulk пишет пачку в формате bulk API Elasticsearch, документы - как в JSONEncoder
+func (s *HTTPShipper) encodeBulk(w io.Writer, batch []Record) error {
+	action, err := json.Marshal(map[string]map[string]string{"index": {"_index": s.index}})
+	if err != nil {
+		return err
+	}
+	action = append(action, '\n')
+	var buf []byte
+	for i := range batch {
+		buf = append(buf, action...)
+		buf = JSONEncoder{}.Encode(buf, &batch[i], false)
+	}
+	_, err = w.Write(buf)
+	return err
+}
+
+// ship отправляет тело запроса, повторяя при сетевых ошибках, 429 и 5xx
+//	* ctx	- context.Context
+//	* body	- []byte
+//	* once	- не повторять
+func (s *HTTPShipper) ship(ctx context.Context, body []byte, once bool) (err error) {
+	backoff := s.backoff
+	for attempt := 0; ; attempt++ {
+		var retry bool
+		if retry, err = s.post(ctx, body); err == nil || !retry || once || attempt >= s.retries {
+			return
+		}
+		time.Sleep(backoff)
+		if backoff *= 2; backoff > shipMaxBackoff {
+			backoff = shipMaxBackoff
+		}
+	}
+}
+
+// post отправляет один запрос, retry - имеет ли смысл повторить его при ошибке
+// В ошибках адрес выводится без пароля и параметров запроса
+func (s *HTTPShipper) post(ctx context.Context, body []byte) (retry bool, err error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return false, fmt.Errorf("%v: invalid request", s.safeURL)
+	}
+	for key, values := range s.header {
+		req.Header[key] = values
+	}
+	if s.format == ShipElasticsearch {
+		req.Header.Set("Content-Type", "application/x-ndjson")
+	} else {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.Header.Set("Content-Encoding", "gzip")
+	resp, err := s.client.Do(req)
+	if urlErr, ok := err.(*url.Error); ok {
+		return true, fmt.Errorf("%v: %v", s.safeURL, urlErr.Err)
+	} else if err != nil {
+		return true, err
+	}
+	message, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+	resp.Body.Close()
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return false, nil
+	}
+	retry = resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+	return retry, fmt.Errorf("%v: %v %s", s.safeURL, resp.Status, bytes.TrimSpace(message))
+}
+
+// safeURL возвращает адрес без пароля и параметров запроса
+func safeURL(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "(invalid url)"
+	}
+	if u.User != nil {
+		if _, ok := u.User.Password(); ok {
+			u.User = url.UserPassword(u.User.Username(), RedactMask)
+		}
+	}
+	if u.RawQuery != "" {
+		u.RawQuery = RedactMask
+	}
+	return u.String()
+}
+
+// spool сохраняет неотправленную пачку в ShipSpoolDir
+func (s *HTTPShipper) spool(body []byte) {
+	if s.spoolDir == "" || len(body) == 0 {
+		return
+	}
+	fileName := filepath.Join(s.spoolDir, strconv.FormatInt(time.Now().UnixNano(), 10)+".gz")
+	if err := ioutil.WriteFile(fileName+".tmp", body, 0644); err != nil {
+		s.logger.reportError("log spool error: %v", err)
+		return
+	}
+	if err := os.Rename(fileName+".tmp", fileName); err != nil {
+		s.logger.reportError("log spool error: %v", err)
+		return
+	}
+	files := s.spoolFiles()
+	for len(files) > shipMaxSpoolFiles {
+		os.Remove(files[0])
+		files = files[1:]
+	}
+}
+
+// spoolFiles возвращает сохраненные пачки, от старых к новым
+func (s *HTTPShipper) spoolFiles() []string {
+	files, _ := filepath.Glob(filepath.Join(s.spoolDir, "*.gz"))
+	sort.Strings(files)
+	return files
+}
+
+// resendSpool отправляет сохраненные пачки, пока отправка удается
+func (s *HTTPShipper) resendSpool() {
+	if s.spoolDir == "" {
+		return
+	}
+	for _, fileName := range s.spoolFiles() {
+		body, err := ioutil.ReadFile(fileName)
+		if err != nil {
+			continue
+		}
+		if retry, err := s.post(context.Background(), body); err != nil && retry {
+			return
+		}
+		os.Remove(fileName)
+	}
+}
+
+// Close отправляет оставшиеся записи и останавливает отправку
+func (s *HTTPShipper) Close() error {
+	s.closeOnce.Do(func() {
+		ExitWaitChans.Remove(s.exitWait)
+	})
+	<-s.done
+	return nil
+}
@@ -0,0 +1,162 @@
+package common
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// shipServer stand-in Loki/Elasticsearch: распаковывает и запоминает тела запросов,
+// на первые failures запросов отвечает 503
+type shipServer struct {
+	*httptest.Server
+	mutex    sync.Mutex
+	failures int
+	bodies   []string
+}
+
+func newShipServer(t *testing.T, failures int) *shipServer {
+	s := &shipServer{failures: failures}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mutex.Lock()
+		defer s.mutex.Unlock()
+		if s.failures > 0 {
+			s.failures--
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		gz, err := gzip.NewReader(r.Body)
+		if err != nil || r.Header.Get("Content-Encoding") != "gzip" {
+			t.Errorf("body is not gzipped: %v", err)
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		body, _ := ioutil.ReadAll(gz)
+		s.bodies = append(s.bodies, string(body))
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	return s
+}
+
+func (s *shipServer) received() []string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return append([]string(nil), s.bodies...)
+}
+
+func TestHTTPShipperLoki(t *testing.T) {
+	server := newShipServer(t, 2)
+	defer server.Close()
+	shipper, err := NewHTTPShipper(ShipLoki, server.URL, ShipLabels{"bot"}, ShipBatchSize(3), ShipBackoff(time.Millisecond), ShipFlushInterval(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	l := NewLogger(shipper, LevelDebug)
+	l.With("bot", "a").Info("started")
+	l.With("bot", "a").Error("failed")
+	l.Named("pdg").With("bot", "b").Info("compacted")
+
+	// Полная пачка отправляется сразу, с повторами после 503
+	deadline := time.Now().Add(5 * time.Second)
+	for len(server.received()) == 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	shipper.Close()
+	bodies := server.received()
+	if len(bodies) != 1 {
+		t.Fatalf("expected 1 request, got %q", bodies)
+	}
+	var request struct {
+		Streams []lokiStream
+	}
+	if err := json.Unmarshal([]byte(bodies[0]), &request); err != nil {
+		t.Fatal(err)
+	}
+	if len(request.Streams) != 3 || request.Streams[0].Stream["bot"] != "a" || request.Streams[0].Stream["level"] != "info" ||
+		request.Streams[2].Stream["logger"] != "pdg" || !strings.Contains(request.Streams[1].Values[0][1], "log-ship_test.go:") {
+		t.Fatalf("unexpected request: %v", bodies[0])
+	}
+}
+
+func TestHTTPShipperSpool(t *testing.T) {
+	dir, err := ioutil.TempDir("", "spool")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	spoolDir := filepath.Join(dir, "spool")
+	down := newShipServer(t, 0)
+	down.Close()
+	shipper, err := NewHTTPShipper(ShipElasticsearch, down.URL, ShipSpoolDir(spoolDir), ShipIndex("bots"), ShipRetries(1), ShipBackoff(time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+	NewLogger(shipper, LevelDebug).Warn("while offline")
+	shipper.Close()
+	if files, _ := filepath.Glob(filepath.Join(spoolDir, "*.gz")); len(files) != 1 {
+		t.Fatalf("expected 1 spooled batch, got %v", files)
+	}
+
+	server := newShipServer(t, 0)
+	defer server.Close()
+	shipper, err = NewHTTPShipper(ShipElasticsearch, server.URL, ShipSpoolDir(spoolDir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	shipper.Close()
+	bodies := server.received()
+	if len(bodies) != 1 || !strings.HasPrefix(bodies[0], `{"index":{"_index":"bots"}}`+"\n") ||
+		!strings.Contains(bodies[0], `"msg":"while offline"`) {
+		t.Fatalf("unexpected requests: %q", bodies)
+	}
+	if files, _ := filepath.Glob(filepath.Join(spoolDir, "*.gz")); len(files) != 0 {
+		t.Fatalf("spool must be empty, got %v", files)
+	}
+}
+
+func TestHTTPShipperExitTimeout(t *testing.T) {
+	dir, err := ioutil.TempDir("", "spool")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer server.Close()
+	defer close(release)
+
+	l, _ := newTestLogger(LevelDebug)
+	var fallback strings.Builder
+	l.SetFallbackWriter(&fallback)
+	shipURL := strings.Replace(server.URL, "http://", "http://shipper:p4ssw0rd@", 1) + "/_bulk?api_key=k3y"
+	shipper, err := NewHTTPShipper(ShipElasticsearch, shipURL, ShipSpoolDir(dir), ShipExitTimeout(100*time.Millisecond),
+		ShipFlushInterval(time.Hour), l)
+	if err != nil {
+		t.Fatal(err)
+	}
+	shipper.WriteRecord(&Record{Time: time.Now(), Level: LevelInfo, Message: "on exit"})
+	start := time.Now()
+	shipper.Close()
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Fatalf("final flush took %v", elapsed)
+	}
+	if files, _ := filepath.Glob(filepath.Join(dir, "*.gz")); len(files) != 1 {
+		t.Fatalf("expected 1 spooled batch, got %v", files)
+	}
+	l.mutex.Lock()
+	reported := fallback.String()
+	l.mutex.Unlock()
+	if !strings.Contains(reported, "log shipping error: ") || strings.Contains(reported, "p4ssw0rd") || strings.Contains(reported, "k3y") {
+		t.Fatalf("unexpected reported error: %q", reported)
+	}
+}
@@ -0,0 +1,170 @@
+//go:build go1.21
+// +build go1.21
+
+package common
+
+import (
+	"context"
+	"log/slog"
+	"runtime"
+	"time"
+)
+
+// levelFromSlog возвращает уровень логгинга для уровня slog
+// Уровни между slog.LevelDebug и slog.LevelInfo соответствуют LevelVerbose
+func levelFromSlog(level slog.Level) logLevels {
+	switch {
+	case level >= slog.LevelError:
+		return LevelError
+	case level >= slog.LevelWarn:
+		return LevelWarn
+	case level >= slog.LevelInfo:
+		return LevelInfo
+	case level > slog.LevelDebug:
+		return LevelVerbose
+	default:
+		return LevelDebug
+	}
+}
+
+// levelToSlog возвращает уровень slog для уровня логгинга
+func levelToSlog(level logLevels) slog.Level {
+	switch level {
+	case LevelDebug:
+		return slog.LevelDebug
+	case LevelVerbose:
+		return slog.LevelDebug + 2
+	case LevelInfo:
+		return slog.LevelInfo
+	case LevelWarn:
+		return slog.LevelWarn
+	case LevelError:
+		return slog.LevelError
+	default:
+		return slog.LevelError + 4
+	}
+}
+
+// SlogHandler slog.Handler, выводящий записи через *Logger
+// Атрибуты становятся полями, группы - префиксами имен полей через точку ("http.status")
+type SlogHandler struct {
+	logger *Logger
+	group  string
+}
+
+// NewSlogHandler создает slog.Handler поверх логгера:
+// slog.SetDefault(slog.New(common.NewSlogHandler(common.Log.Named("lib"))))
+//	* l - *Logger, nil - Log
+func NewSlogHandler(l *Logger) *SlogHandler {
+	if l == nil {
+		l = Log
+	}
+	return &SlogHandler{logger: l}
+}
+
+// Enabled проверяет уровень логгера (с учетом FingersCrossed логгера из контекста)
+func (h *SlogHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.logger.FromContext(ctx).enabled(levelFromSlog(level))
+}
+
+// Handle выводит запись slog, вызывающий берется из r.PC
+func (h *SlogHandler) Handle(ctx context.Context, r slog.Record) error {
+	l := h.logger.FromContext(ctx)
+	level := levelFromSlog(r.Level)
+	if r.Time.IsZero() {
+		r.Time = time.Now()
+	}
+	fields := make([]Field, len(l.fields), len(l.fields)+r.NumAttrs())
+	copy(fields, l.fields)
+	r.Attrs(func(attr slog.Attr) bool {
+		fields = appendSlogAttr(fields, h.group, attr)
+		return true
+	})
+	record := Record{Time: r.Time, Level: level, Name: l.Name(), Message: r.Message, Fields: fields}
+	record.Err = findError(nil, fields)
+	if r.PC != 0 {
+		frame, _ := runtime.CallersFrames([]uintptr{r.PC}).Next()
+		record.PC = r.PC
+		record.File = frame.File
+		record.Line = frame.Line
+		record.Func = frame.Function
+	}
+	if level >= l.getStackLevel() {
+		record.Stack = stackFromCaller(&record)
+	}
+	l.output(&record)
+	return nil
+}
+
+// WithAttrs возвращает обработчик, добавляющий атрибуты к каждой записи
+func (h *SlogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	var fields []Field
+	for _, attr := range attrs {
+		fields = appendSlogAttr(fields, h.group, attr)
+	}
+	return &SlogHandler{logger: h.logger.With(fields), group: h.group}
+}
+
+// WithGroup возвращает обработчик, добавляющий префикс name к именам следующих атрибутов
+func (h *SlogHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	return &SlogHandler{logger: h.logger, group: h.group + name + "."}
+}
+
+// appendSlogAttr дописывает атрибут в поля, раскрывая группы
+func appendSlogAttr(fields []Field, prefix string, attr slog.Attr) []Field {
+	attr.Value = attr.Value.Resolve()
+	if attr.Equal(slog.Attr{}) {
+		return fields
+	}
+	if attr.Value.Kind() == slog.KindGroup {
+		if attr.Key != "" {
+			prefix += attr.Key + "."
+		}
+		for _, groupAttr := range attr.Value.Group() {
+			fields = appendSlogAttr(fields, prefix, groupAttr)
+		}
+		return fields
+	}
+	return append(fields, Field{prefix + attr.Key, attr.Value.Any()})
+}
+
+// slogWriter writer, передающий записи в slog.Handler
+type slogWriter struct {
+	handler slog.Handler
+}
+
+// NewSlogLogger создает логгер, выводящий записи в slog.Handler
+// Имя логгера (см. Named) передается атрибутом "logger"
+//	* handler	- slog.Handler
+//	* level		- уровень логгинга
+func NewSlogLogger(handler slog.Handler, level logLevels) *Logger {
+	return NewLogger(&slogWriter{handler: handler}, level)
+}
+
+// WriteRecord передает запись в slog.Handler
+func (w *slogWriter) WriteRecord(r *Record) error {
+	ctx := context.Background()
+	level := levelToSlog(r.Level)
+	if !w.handler.Enabled(ctx, level) {
+		return nil
+	}
+	record := slog.NewRecord(r.Time, level, r.Message, r.PC)
+	if r.Name != "" {
+		record.AddAttrs(slog.String("logger", r.Name))
+	}
+	for _, field := range r.Fields {
+		record.AddAttrs(slog.Any(field.Key, field.Value))
+	}
+	return w.handler.Handle(ctx, record)
+}
+
+// Write передает p в slog.Handler как сообщение уровня LevelInfo
+func (w *slogWriter) Write(p []byte) (int, error) {
+	if err := w.WriteRecord(&Record{Time: time.Now(), Level: LevelInfo, Message: string(p)}); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
@@ -0,0 +1,49 @@
+//go:build go1.21
+// +build go1.21
+
+package common
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestSlogHandler(t *testing.T) {
+	l, buf := newTestLogger(LevelVerbose)
+	logger := slog.New(NewSlogHandler(l.Named("lib"))).With("conn", 3).WithGroup("http")
+	logger.Debug("hidden")
+	logger.Info("request", "status", 200, slog.Group("req", "method", "GET"))
+	out := buf.String()
+	if strings.Contains(out, "hidden") {
+		t.Fatalf("debug message must be filtered: %q", out)
+	}
+	if !strings.Contains(out, " lib: request conn=3 http.status=200 http.req.method=GET\n") ||
+		!strings.Contains(out, "[log-slog_test.go:") {
+		t.Fatalf("unexpected output: %q", out)
+	}
+}
+
+func TestSlogLogger(t *testing.T) {
+	buf := &bytes.Buffer{}
+	handler := slog.NewJSONHandler(buf, &slog.HandlerOptions{AddSource: true, Level: slog.LevelDebug})
+	l := NewSlogLogger(handler, LevelInfo)
+	l.Debug("hidden")
+	l.Named("pdg").With("id", 5).Warnf("put %v", "user")
+	var entry struct {
+		Level  string
+		Msg    string
+		Logger string
+		ID     int
+		Source struct{ File string }
+	}
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatalf("%v: %q", err, buf.String())
+	}
+	if entry.Level != "WARN" || entry.Msg != "put user" || entry.Logger != "pdg" || entry.ID != 5 ||
+		!strings.HasSuffix(entry.Source.File, "log-slog_test.go") {
+		t.Fatalf("unexpected output: %q", buf.String())
+	}
+}
@@ -0,0 +1,163 @@
+package common
+
+import (
+	"errors"
+	"runtime"
+	"strings"
+	"sync/atomic"
+)
+
+// LevelOff уровень выше LevelFatal: SetLogLevel(LevelOff) выключает логгер, SetStackTraceLevel(LevelOff) - стек вызовов
+const LevelOff = LevelFatal + 1
+
+// maxStackDepth максимальное количество кадров в стеке вызовов
+const maxStackDepth = 32
+
+// StackFrame кадр стека вызовов
+type StackFrame struct {
+	Func string
+	File string
+	Line int
+}
+
+// captureStack возвращает стек вызовов без кадров пакета runtime
+//	* skip - сколько кадров пропустить (0 - вызвавший captureStack)
+func captureStack(skip int) []StackFrame {
+	var pcs [maxStackDepth]uintptr
+	return framesOf(pcs[:runtime.Callers(skip+2, pcs[:])])
+}
+
+func framesOf(pcs []uintptr) (stack []StackFrame) {
+	if len(pcs) == 0 {
+		return
+	}
+	stack = make([]StackFrame, 0, len(pcs))
+	frames := runtime.CallersFrames(pcs)
+	for {
+		frame, more := frames.Next()
+		if !strings.HasPrefix(frame.Function, "runtime.") {
+			stack = append(stack, StackFrame{Func: frame.Function, File: frame.File, Line: frame.Line})
+		}
+		if !more {
+			break
+		}
+	}
+	return
+}
+
+// stackError ошибка со стеком вызовов места создания (см. SetErrorStackTraces)
+type stackError struct {
+	err   error
+	stack []uintptr
+}
+
+func (e *stackError) Error() string {
+	return e.err.Error()
+}
+
+func (e *stackError) Unwrap() error {
+	return e.err
+}
+
+// StackTrace возвращает стек вызовов места создания ошибки
+func (e *stackError) StackTrace() []StackFrame {
+	return framesOf(e.stack)
+}
+
+// stackTracer ошибка, которая знает стек вызовов места своего создания
+type stackTracer interface {
+	StackTrace() []StackFrame
+}
+
+var errorStackTraces int32
+
+// SetErrorStackTraces включает запоминание стека вызовов в ошибках, созданных Errorf
+// Логгер выводит для таких ошибок стек места создания ошибки, а не места вызова Log.Error
+//	* enabled - bool
+func SetErrorStackTraces(enabled bool) {
+	var value int32
+	if enabled {
+		value = 1
+	}
+	atomic.StoreInt32(&errorStackTraces, value)
+}
+
+// withStack добавляет к ошибке стек вызовов, если это включено и стека в ней еще нет
+//	* skip - сколько кадров пропустить (0 - вызвавший withStack)
+func withStack(err error, skip int) error {
+	if err == nil || atomic.LoadInt32(&errorStackTraces) == 0 {
+		return err
+	}
+	var tracer stackTracer
+	if errors.As(err, &tracer) {
+		return err
+	}
+	var pcs [maxStackDepth]uintptr
+	n := runtime.Callers(skip+2, pcs[:])
+	return &stackError{err: err, stack: append([]uintptr(nil), pcs[:n]...)}
+}
+
+// SetStackTraceLevel устанавливает уровень, начиная с которого к записям добавляется стек вызовов
+// (по умолчанию LevelOff - никогда). Для именованного логгера (см. Named) - только для него
+//	* level - logLevels
+func (l *Logger) SetStackTraceLevel(level logLevels) {
+	if l.component != nil {
+		atomic.StoreInt32(&l.component.stackLevel, int32(level))
+		return
+	}
+	atomic.StoreInt32(&l.stackLevel, int32(level))
+}
+
+// getStackLevel возвращает действующий уровень для стека вызовов с учетом именованных родителей
+func (l *Logger) getStackLevel() logLevels {
+	for c := l.component; c != nil; c = c.parent {
+		if level := logLevels(atomic.LoadInt32(&c.stackLevel)); level != levelInherit {
+			return level
+		}
+	}
+	return logLevels(atomic.LoadInt32(&l.stackLevel))
+}
+
+// stackFor возвращает стек вызовов для записи: стек создания ошибки, если он есть, иначе текущий
+//	* skip - сколько кадров пропустить (0 - вызвавший stackFor)
+func stackFor(err error, skip int) []StackFrame {
+	var tracer stackTracer
+	if err != nil && errors.As(err, &tracer) {
+		return tracer.StackTrace()
+	}
+	return captureStack(skip + 1)
+}
+
+// stackFromCaller возвращает стек вызовов для записи r, у которой вызывающий определен не через
+// пропуском кадров (slog, стандартный log): кадры до r.File:r.Line отбрасываются
+func stackFromCaller(r *Record) []StackFrame {
+	stack := stackFor(r.Err, 1)
+	for i, frame := range stack {
+		if frame.File == r.File && frame.Line == r.Line {
+			return stack[i:]
+		}
+	}
+	return stack
+}
+
+// appendStackText дописывает стек вызовов в текстовом виде, по кадру на строку
+func appendStackText(buf *[]byte, stack []StackFrame) {
+	for _, frame := range stack {
+		*buf = append(*buf, "\t\tCalled from "...)
+		*buf = append(*buf, frame.File...)
+		*buf = append(*buf, ':')
+		itoa(buf, frame.Line, -1)
+		*buf = append(*buf, " ("...)
+		*buf = append(*buf, frame.Func...)
+		*buf = append(*buf, ")\n"...)
+	}
+}
+
+// appendFrame дописывает кадр в виде "func file:line"
+func appendFrame(buf *[]byte, frame StackFrame) {
+	*buf = append(*buf, frame.Func...)
+	*buf = append(*buf, ' ')
+	*buf = append(*buf, frame.File...)
+	*buf = append(*buf, ':')
+	itoa(buf, frame.Line, -1)
+}
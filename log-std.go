@@ -0,0 +1,93 @@
+package common
+
+import (
+	"fmt"
+	"log"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// stdLogWriter writer для стандартного пакета log: каждая строка становится записью логгера
+type stdLogWriter struct {
+	logger *Logger
+	level  logLevels
+}
+
+// RedirectStdLog перенаправляет стандартный пакет log в логгер (log.Printf и т.п. в сторонних
+// библиотеках выводятся в нашем формате и во все writer'ы логгера)
+// log.Fatal и log.Panic по-прежнему завершают процесс сами, минуя Exit
+//	* level - уровень, с которым выводятся сообщения
+func (l *Logger) RedirectStdLog(level logLevels) {
+	log.SetFlags(0)
+	log.SetPrefix("")
+	log.SetOutput(&stdLogWriter{logger: l, level: level})
+}
+
+// StdLogger возвращает *log.Logger, выводящий в логгер (например, для http.Server.ErrorLog)
+//	* level - уровень, с которым выводятся сообщения
+func (l *Logger) StdLogger(level logLevels) *log.Logger {
+	return log.New(&stdLogWriter{logger: l, level: level}, "", 0)
+}
+
+// Write выводит строку p, вызывающим считается первый кадр вне пакета log
+func (w *stdLogWriter) Write(p []byte) (int, error) {
+	l := w.logger
+	if !l.enabled(w.level) {
+		return len(p), nil
+	}
+	r := Record{Time: time.Now(), Level: w.level, Name: l.Name(), Message: strings.TrimSuffix(string(p), "\n"), Fields: l.fields}
+	r.Err = findError(nil, l.fields)
+	var pcs [maxStackDepth]uintptr
+	for _, pc := range pcs[:runtime.Callers(2, pcs[:])] {
+		frame, _ := runtime.CallersFrames([]uintptr{pc}).Next()
+		if !strings.HasPrefix(frame.Function, "log.") {
+			r.PC = pc
+			r.File = frame.File
+			r.Line = frame.Line
+			r.Func = frame.Function
+			break
+		}
+	}
+	if r.Level >= l.getStackLevel() {
+		r.Stack = stackFromCaller(&r)
+	}
+	l.output(&r)
+	return len(p), nil
+}
+
+// RecoverPanic выводит панику со стеком вызовов места паники как LevelFatal и завершается через Exit
+// Должна вызываться через defer в начале main и горутин: defer common.Log.RecoverPanic()
+func (l *Logger) RecoverPanic() {
+	value := recover()
+	if value == nil {
+		return
+	}
+	r := Record{Time: time.Now(), Level: LevelFatal, Name: l.Name(), Message: fmt.Sprintf("panic: %v", value), Fields: l.fields}
+	if err, ok := value.(error); ok {
+		r.Err = err
+	} else {
+		r.Err = findError(nil, l.fields)
+	}
+	r.Stack = panicStack()
+	if len(r.Stack) > 0 {
+		r.File = r.Stack[0].File
+		r.Line = r.Stack[0].Line
+		r.Func = r.Stack[0].Func
+	}
+	l.output(&r)
+	Exit(-1)
+}
+
+// panicStack возвращает стек вызовов с места паники (кадры после runtime.gopanic)
+func panicStack() []StackFrame {
+	var pcs [maxStackDepth]uintptr
+	n := runtime.Callers(3, pcs[:])
+	for i := 0; i < n; i++ {
+		frame, _ := runtime.CallersFrames(pcs[i : i+1]).Next()
+		if frame.Function == "runtime.gopanic" {
+			return framesOf(pcs[i+1 : n])
+		}
+	}
+	return framesOf(pcs[:n])
+}
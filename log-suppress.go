@@ -0,0 +1,242 @@
+package common
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// suppressKey место вызова, для которого считаются повторы и ограничение частоты
+type suppressKey struct {
+	file  string
+	line  int
+	level logLevels
+	name  string
+}
+
+// dedupeKey место вызова и текст сообщения
+type dedupeKey struct {
+	suppressKey
+	message string
+}
+
+// dedupeEntry одинаковые сообщения с одного места вызова
+type dedupeEntry struct {
+	start    time.Time
+	repeated int
+	last     Record
+}
+
+// rateBucket token bucket места вызова
+type rateBucket struct {
+	tokens     float64
+	updated    time.Time
+	suppressed int
+	last       Record
+}
+
+// rateLimit ограничение частоты сообщений одного уровня
+type rateLimit struct {
+	perSecond float64
+	burst     float64
+}
+
+// logSuppressor схлопывает повторы и ограничивает частоту сообщений
+type logSuppressor struct {
+	mutex      sync.Mutex
+	window     time.Duration
+	limits     map[logLevels]rateLimit
+	dedupe     map[dedupeKey]*dedupeEntry
+	buckets    map[suppressKey]*rateBucket
+	suppressed uint64
+	stop       chan struct{}
+	stopped    chan struct{}
+}
+
+func newLogSuppressor() *logSuppressor {
+	return &logSuppressor{
+		limits:  make(map[logLevels]rateLimit, 4),
+		dedupe:  make(map[dedupeKey]*dedupeEntry, 16),
+		buckets: make(map[suppressKey]*rateBucket, 16),
+	}
+}
+
+// repeatedSummary запись "last message repeated N times" по последнему подавленному сообщению
+func repeatedSummary(last *Record, repeated int, format string) *Record {
+	summary := *last
+	summary.Time = time.Now().In(last.Time.Location())
+	summary.Message = fmt.Sprintf(format, repeated, last.Message)
+	summary.Stack = nil
+	return &summary
+}
+
+// allow проверяет, выводить ли запись
+// Возвращает также итоги по ранее подавленным сообщениям, которые надо вывести перед r
+func (s *logSuppressor) allow(r *Record) (allowed bool, summaries []*Record) {
+	if r.Level >= LevelFatal {
+		return true, nil
+	}
+	key := suppressKey{file: r.File, line: r.Line, level: r.Level, name: r.Name}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if limit, ok := s.limits[r.Level]; ok {
+		bucket, ok := s.buckets[key]
+		if !ok {
+			bucket = &rateBucket{tokens: limit.burst, updated: r.Time}
+			s.buckets[key] = bucket
+		}
+		bucket.tokens += r.Time.Sub(bucket.updated).Seconds() * limit.perSecond
+		if bucket.tokens > limit.burst {
+			bucket.tokens = limit.burst
+		}
+		bucket.updated = r.Time
+		if bucket.tokens < 1 {
+			bucket.suppressed++
+			bucket.last = *r
+			s.suppressed++
+			return false, nil
+		}
+		bucket.tokens--
+		if bucket.suppressed > 0 {
+			summaries = append(summaries, repeatedSummary(&bucket.last, bucket.suppressed, "%d messages suppressed by rate limit, last: %s"))
+			bucket.suppressed = 0
+		}
+	}
+
+	if s.window > 0 {
+		dkey := dedupeKey{key, r.Message}
+		entry, ok := s.dedupe[dkey]
+		if ok && r.Time.Sub(entry.start) < s.window {
+			entry.repeated++
+			entry.last = *r
+			s.suppressed++
+			return false, summaries
+		}
+		if ok && entry.repeated > 0 {
+			summaries = append(summaries, repeatedSummary(&entry.last, entry.repeated, "last message repeated %d times: %s"))
+		}
+		s.dedupe[dkey] = &dedupeEntry{start: r.Time}
+	}
+	return true, summaries
+}
+
+// expired возвращает итоги по подавленным сообщениям, окно которых истекло (все - если force),
+// и удаляет устаревшие записи
+func (s *logSuppressor) expired(now time.Time, force bool) (summaries []*Record) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for key, entry := range s.dedupe {
+		if !force && now.Sub(entry.start) < s.window {
+			continue
+		}
+		if entry.repeated > 0 {
+			summaries = append(summaries, repeatedSummary(&entry.last, entry.repeated, "last message repeated %d times: %s"))
+		}
+		delete(s.dedupe, key)
+	}
+	for key, bucket := range s.buckets {
+		if bucket.suppressed > 0 {
+			summaries = append(summaries, repeatedSummary(&bucket.last, bucket.suppressed, "%d messages suppressed by rate limit, last: %s"))
+			bucket.suppressed = 0
+		} else if limit := s.limits[key.level]; limit.perSecond <= 0 ||
+			now.Sub(bucket.updated).Seconds()*limit.perSecond+bucket.tokens >= limit.burst {
+			delete(s.buckets, key)
+		}
+	}
+	return
+}
+
+// getSuppressor возвращает (создает и запускает) подавитель повторов логгера
+func (l *Logger) getSuppressor() *logSuppressor {
+	l.stateMutex.Lock()
+	defer l.stateMutex.Unlock()
+	if l.suppressor == nil {
+		l.suppressor = newLogSuppressor()
+		l.suppressor.stop = make(chan struct{})
+		l.suppressor.stopped = make(chan struct{})
+		go l.runSuppressor(l.suppressor)
+	}
+	return l.suppressor
+}
+
+// runSuppressor периодически выводит итоги по подавленным сообщениям
+func (l *Logger) runSuppressor(s *logSuppressor) {
+	defer close(s.stopped)
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case now := <-ticker.C:
+			for _, summary := range s.expired(now, false) {
+				l.emit(summary)
+			}
+		case <-s.stop:
+			for _, summary := range s.expired(time.Now(), true) {
+				l.emit(summary)
+			}
+			return
+		}
+	}
+}
+
+// stopSuppressor выводит итоги по всем подавленным сообщениям и выключает подавление
+func (l *Logger) stopSuppressor() {
+	l.stateMutex.Lock()
+	s := l.suppressor
+	l.suppressor = nil
+	l.stateMutex.Unlock()
+	if s == nil {
+		return
+	}
+	close(s.stop)
+	<-s.stopped
+	s.mutex.Lock()
+	suppressed := s.suppressed
+	s.mutex.Unlock()
+	l.stateMutex.Lock()
+	l.suppressedTotal += suppressed
+	l.stateMutex.Unlock()
+}
+
+// SetDedupe включает схлопывание одинаковых сообщений с одного места вызова:
+// повторы в течение window не выводятся, вместо них выводится "last message repeated N times"
+//	* window - time.Duration, 0 - выключить
+func (l *Logger) SetDedupe(window time.Duration) {
+	s := l.getSuppressor()
+	s.mutex.Lock()
+	s.window = window
+	s.mutex.Unlock()
+}
+
+// SetRateLimit ограничивает частоту сообщений уровня level с одного места вызова (token bucket),
+// о подавленных сообщениях периодически выводится итог
+//	* level		- logLevels
+//	* perSecond	- сколько сообщений в секунду, 0 - без ограничения
+//	* burst		- сколько сообщений можно вывести подряд
+func (l *Logger) SetRateLimit(level logLevels, perSecond float64, burst int) {
+	s := l.getSuppressor()
+	s.mutex.Lock()
+	if perSecond <= 0 {
+		delete(s.limits, level)
+	} else {
+		if burst < 1 {
+			burst = 1
+		}
+		s.limits[level] = rateLimit{perSecond: perSecond, burst: float64(burst)}
+	}
+	s.mutex.Unlock()
+}
+
+// Suppressed возвращает количество записей, подавленных SetDedupe и SetRateLimit
+func (l *Logger) Suppressed() (suppressed uint64) {
+	l.stateMutex.Lock()
+	defer l.stateMutex.Unlock()
+	suppressed = l.suppressedTotal
+	if l.suppressor != nil {
+		l.suppressor.mutex.Lock()
+		suppressed += l.suppressor.suppressed
+		l.suppressor.mutex.Unlock()
+	}
+	return
+}
@@ -0,0 +1,216 @@
+package common
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Параметры NewSyslogWriter и NewJournaldWriter
+type (
+	// SyslogFacility facility syslog (по умолчанию 1 - user)
+	SyslogFacility int
+	// SyslogAppName имя приложения (по умолчанию - имя исполняемого файла)
+	SyslogAppName string
+)
+
+// syslogSDID идентификатор структурированных данных RFC 5424 (32473 - номер для примеров из RFC 5612)
+const syslogSDID = "fields@32473"
+
+// syslogSeverity возвращает severity syslog для уровня логгинга
+func syslogSeverity(level logLevels) int {
+	switch level {
+	case LevelFatal:
+		return 2 // crit
+	case LevelError:
+		return 3 // err
+	case LevelWarn:
+		return 4 // warning
+	case LevelInfo:
+		return 6 // info
+	default:
+		return 7 // debug
+	}
+}
+
+func defaultAppName() string {
+	return filepath.Base(os.Args[0])
+}
+
+// SyslogWriter writer, отправляющий записи в syslog в формате RFC 5424
+type SyslogWriter struct {
+	network  string
+	addr     string
+	facility int
+	hostname string
+	appName  string
+	procID   string
+
+	mutex  sync.Mutex
+	conn   net.Conn
+	closed bool
+	buf    []byte
+	value  []byte
+}
+
+// NewSyslogWriter создает writer в syslog
+//	* network	- "unixgram", "udp" или "tcp" (для tcp используется octet counting из RFC 6587),
+//				  "" - локальный syslog (/dev/log, /var/run/syslog или /var/run/log)
+//	* addr		- адрес или путь к сокету
+//	* params	- SyslogFacility, SyslogAppName
+func NewSyslogWriter(network, addr string, params ...interface{}) (w *SyslogWriter, err error) {
+	w = &SyslogWriter{
+		network:  network,
+		addr:     addr,
+		facility: 1,
+		appName:  defaultAppName(),
+		procID:   strconv.Itoa(os.Getpid()),
+	}
+	for _, param := range params {
+		switch param := param.(type) {
+		case SyslogFacility:
+			w.facility = int(param)
+		case SyslogAppName:
+			w.appName = string(param)
+		}
+	}
+	if w.hostname, err = os.Hostname(); err != nil || w.hostname == "" {
+		w.hostname = "-"
+	}
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	if err = w.connect(); err != nil {
+		return nil, err
+	}
+	return
+}
+
+func (w *SyslogWriter) connect() (err error) {
+	if w.network != "" {
+		w.conn, err = net.Dial(w.network, w.addr)
+		return
+	}
+	for _, path := range []string{"/dev/log", "/var/run/syslog", "/var/run/log"} {
+		if w.conn, err = net.Dial("unixgram", path); err == nil {
+			return
+		}
+	}
+	return
+}
+
+// WriteRecord отправляет запись в syslog
+func (w *SyslogWriter) WriteRecord(r *Record) error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	if w.closed {
+		return os.ErrClosed
+	}
+	w.buf = w.buf[:0]
+	w.buf = append(w.buf, '<')
+	w.buf = strconv.AppendInt(w.buf, int64(w.facility*8+syslogSeverity(r.Level)), 10)
+	w.buf = append(w.buf, ">1 "...)
+	appendTimestamp(&w.buf, r.Time)
+	w.buf = append(w.buf, ' ')
+	w.buf = append(w.buf, w.hostname...)
+	w.buf = append(w.buf, ' ')
+	w.buf = append(w.buf, w.appName...)
+	w.buf = append(w.buf, ' ')
+	w.buf = append(w.buf, w.procID...)
+	w.buf = append(w.buf, " - "...)
+	w.appendStructuredData(r)
+	w.buf = append(w.buf, ' ')
+	w.buf = append(w.buf, strings.TrimRight(r.Message, "\n")...)
+	return w.send()
+}
+
+// appendStructuredData дописывает поля, вызывающего и имя логгера как SD-ELEMENT
+func (w *SyslogWriter) appendStructuredData(r *Record) {
+	if r.File == "" && r.Name == "" && len(r.Fields) == 0 {
+		w.buf = append(w.buf, '-')
+		return
+	}
+	w.buf = append(w.buf, '[')
+	w.buf = append(w.buf, syslogSDID...)
+	if r.File != "" {
+		w.buf = append(w.buf, ` caller="`...)
+		appendCaller(&w.buf, r)
+		w.buf = append(w.buf, '"')
+	}
+	if r.Name != "" {
+		w.appendParam("logger", []byte(r.Name))
+	}
+	for _, field := range r.Fields {
+		w.value = w.value[:0]
+		appendRawValue(&w.value, field.Value)
+		w.appendParam(field.Key, w.value)
+	}
+	w.buf = append(w.buf, ']')
+}
+
+// appendParam дописывает SD-PARAM, приводя имя к допустимому по RFC 5424 и экранируя значение
+func (w *SyslogWriter) appendParam(name string, value []byte) {
+	w.buf = append(w.buf, ' ')
+	for i := 0; i < len(name) && i < 32; i++ {
+		ch := name[i]
+		if ch <= ' ' || ch > '~' || ch == '=' || ch == ']' || ch == '"' {
+			ch = '_'
+		}
+		w.buf = append(w.buf, ch)
+	}
+	w.buf = append(w.buf, '=', '"')
+	for i := 0; i < len(value); i++ {
+		if ch := value[i]; ch == '"' || ch == '\\' || ch == ']' {
+			w.buf = append(w.buf, '\\')
+		}
+		w.buf = append(w.buf, value[i])
+	}
+	w.buf = append(w.buf, '"')
+}
+
+// send отправляет w.buf, при ошибке один раз переподключается
+func (w *SyslogWriter) send() (err error) {
+	msg := w.buf
+	if w.network == "tcp" {
+		msg = strconv.AppendInt(make([]byte, 0, len(w.buf)+8), int64(len(w.buf)), 10)
+		msg = append(msg, ' ')
+		msg = append(msg, w.buf...)
+	}
+	for attempt := 0; attempt < 2; attempt++ {
+		if w.conn == nil {
+			if err = w.connect(); err != nil {
+				return
+			}
+		}
+		w.conn.SetWriteDeadline(time.Now().Add(5 * time.Second))
+		if _, err = w.conn.Write(msg); err == nil {
+			return
+		}
+		w.conn.Close()
+		w.conn = nil
+	}
+	return
+}
+
+// Write отправляет p в syslog как сообщение уровня LevelInfo
+func (w *SyslogWriter) Write(p []byte) (int, error) {
+	if err := w.WriteRecord(&Record{Time: time.Now(), Level: LevelInfo, Message: string(p)}); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// Close закрывает соединение с syslog
+func (w *SyslogWriter) Close() (err error) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	w.closed = true
+	if w.conn != nil {
+		err = w.conn.Close()
+		w.conn = nil
+	}
+	return
+}
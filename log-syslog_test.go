@@ -0,0 +1,82 @@
+package common
+
+import (
+	"encoding/binary"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func listenUnixgram(t *testing.T, dir, name string) *net.UnixConn {
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: filepath.Join(dir, name), Net: "unixgram"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return conn
+}
+
+func TestSyslogWriter(t *testing.T) {
+	dir, err := ioutil.TempDir("", "syslog")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	server := listenUnixgram(t, dir, "log.sock")
+	defer server.Close()
+
+	w, err := NewSyslogWriter("unixgram", filepath.Join(dir, "log.sock"), SyslogFacility(3), SyslogAppName("bot"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	l, _ := newTestLogger(LevelDebug)
+	l.AddWriter(w)
+	l.Named("pdg").With("chat_id", 42, "text", `say "hi"]`).Error("failed")
+	l.Close()
+
+	buf := make([]byte, 4096)
+	n, err := server.Read(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg := string(buf[:n])
+	if !strings.HasPrefix(msg, "<27>1 ") || !strings.Contains(msg, " bot ") ||
+		!strings.Contains(msg, ` - [fields@32473 caller="log-syslog_test.go:`) ||
+		!strings.HasSuffix(msg, ` logger="pdg" chat_id="42" text="say \"hi\"\]"] failed`) {
+		t.Fatalf("unexpected syslog message: %q", msg)
+	}
+}
+
+func TestJournaldWriter(t *testing.T) {
+	dir, err := ioutil.TempDir("", "journald")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	server := listenUnixgram(t, dir, "journal.sock")
+	defer server.Close()
+
+	w, err := NewJournaldWriter(filepath.Join(dir, "journal.sock"), SyslogAppName("bot"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	l, _ := newTestLogger(LevelDebug)
+	l.AddWriter(w)
+	l.With("chat-id", 42).Warn("two\nlines")
+
+	buf := make([]byte, 4096)
+	n, err := server.Read(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	size := make([]byte, 8)
+	binary.LittleEndian.PutUint64(size, uint64(len("two\nlines")))
+	msg := string(buf[:n])
+	if !strings.HasPrefix(msg, "MESSAGE\n"+string(size)+"two\nlines\nPRIORITY=4\nSYSLOG_IDENTIFIER=bot\nCODE_FILE=") ||
+		!strings.Contains(msg, "log-syslog_test.go\nCODE_LINE=") || !strings.HasSuffix(msg, "\nCHAT_ID=42\n") {
+		t.Fatalf("unexpected journald message: %q", msg)
+	}
+}
@@ -0,0 +1,285 @@
+package common
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// Параметры NewTelegramSink
+type (
+	// TelegramBaseURL адрес Bot API (по умолчанию https://api.telegram.org)
+	TelegramBaseURL string
+	// TelegramBatchWindow сколько собирать записи в одно сообщение (по умолчанию 5 секунд)
+	TelegramBatchWindow time.Duration
+	// TelegramMinInterval минимальный интервал между сообщениями (по умолчанию 3 секунды)
+	TelegramMinInterval time.Duration
+	// TelegramExitTimeout сколько отправлять оставшиеся записи при Exit и Close (по умолчанию 5 секунд)
+	TelegramExitTimeout time.Duration
+)
+
+const (
+	// telegramMaxMessage максимальная длина сообщения Bot API (в символах)
+	telegramMaxMessage = 4096
+	// telegramMaxPending сколько байт записей может ждать отправки, остальные выбрасываются
+	telegramMaxPending = 64 * 1024
+	// telegramMaxRetryAfter дольше этого не ждем при ответе 429 Too Many Requests
+	telegramMaxRetryAfter = time.Minute
+)
+
+// TelegramSink writer, отправляющий записи сообщениями в чат Telegram через Bot API
+// Записи собираются в пачки, длинные пачки разбиваются на несколько сообщений,
+// при Exit оставшиеся записи отправляются (не дольше TelegramExitTimeout):
+// sink := common.NewTelegramSink(token, adminChatID); common.Log.AddWriter(sink, common.LevelError)
+type TelegramSink struct {
+	apiURL   string
+	token    string
+	chatID   string
+	header   string
+	window   time.Duration
+	interval time.Duration
+	timeout  time.Duration
+	client   *http.Client
+	logger   *Logger
+	lastSent time.Time
+
+	mutex   sync.Mutex
+	pending []byte
+	dropped int
+	closed  bool
+
+	kick      chan struct{}
+	exitWait  chan WaitChanResult
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// NewTelegramSink создает writer в чат Telegram
+//	* token		- токен бота
+//	* chatID	- id чата (число) или @username канала
+//	* params	- TelegramBaseURL, TelegramBatchWindow, TelegramMinInterval, TelegramExitTimeout, *http.Client,
+//				  *Logger (в запасной writer которого выводятся ошибки отправки, по умолчанию Log)
+func NewTelegramSink(token, chatID string, params ...interface{}) *TelegramSink {
+	baseURL := "https://api.telegram.org"
+	s := &TelegramSink{
+		token:    token,
+		chatID:   chatID,
+		window:   5 * time.Second,
+		interval: 3 * time.Second,
+		timeout:  5 * time.Second,
+		client:   &http.Client{Timeout: 10 * time.Second},
+		logger:   Log,
+		kick:     make(chan struct{}, 1),
+		done:     make(chan struct{}),
+	}
+	for _, param := range params {
+		switch param := param.(type) {
+		case TelegramBaseURL:
+			baseURL = strings.TrimRight(string(param), "/")
+		case TelegramBatchWindow:
+			s.window = time.Duration(param)
+		case TelegramMinInterval:
+			s.interval = time.Duration(param)
+		case TelegramExitTimeout:
+			s.timeout = time.Duration(param)
+		case *http.Client:
+			s.client = param
+		case *Logger:
+			s.logger = param
+		}
+	}
+	s.apiURL = baseURL + "/bot" + token + "/sendMessage"
+	s.header = defaultAppName()
+	if hostname, err := os.Hostname(); err == nil && hostname != "" {
+		s.header += "@" + hostname
+	}
+	s.exitWait = ExitWaitChans.Add()
+	go s.run()
+	return s
+}
+
+// WriteRecord добавляет запись в пачку для отправки
+func (s *TelegramSink) WriteRecord(r *Record) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.closed {
+		return os.ErrClosed
+	}
+	if len(s.pending) >= telegramMaxPending {
+		s.dropped++
+		return nil
+	}
+	s.pending = TextEncoder{}.Encode(s.pending, r, false)
+	select {
+	case s.kick <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// Write добавляет p в пачку как сообщение уровня LevelInfo
+func (s *TelegramSink) Write(p []byte) (int, error) {
+	if err := s.WriteRecord(&Record{Time: time.Now(), Level: LevelInfo, Message: strings.TrimSuffix(string(p), "\n")}); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// run отправляет пачки: первая запись пачки запускает окно TelegramBatchWindow
+func (s *TelegramSink) run() {
+	defer close(s.done)
+	for {
+		select {
+		case <-s.kick:
+		case done, ok := <-s.exitWait:
+			s.stop(done, ok)
+			return
+		}
+		timer := time.NewTimer(s.window)
+		select {
+		case <-timer.C:
+			s.flush(false)
+		case done, ok := <-s.exitWait:
+			timer.Stop()
+			s.stop(done, ok)
+			return
+		}
+	}
+}
+
+// stop отправляет оставшиеся записи и перестает принимать новые
+func (s *TelegramSink) stop(done WaitChanResult, ok bool) {
+	s.flush(true)
+	s.mutex.Lock()
+	s.closed = true
+	s.mutex.Unlock()
+	if ok {
+		done.Done()
+	}
+}
+
+// flush отправляет накопленные записи
+//	* final - при Exit и Close: без ожидания между сообщениями и повторов, не дольше TelegramExitTimeout
+func (s *TelegramSink) flush(final bool) {
+	ctx := context.Background()
+	if final {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.timeout)
+		defer cancel()
+	}
+	s.mutex.Lock()
+	pending, dropped := s.pending, s.dropped
+	s.pending, s.dropped = nil, 0
+	s.mutex.Unlock()
+	if len(pending) == 0 {
+		return
+	}
+	text := s.header + "\n" + string(pending)
+	if dropped > 0 {
+		text += fmt.Sprintf("... and %d more records dropped\n", dropped)
+	}
+	for _, message := range splitMessage(text, telegramMaxMessage) {
+		if err := s.send(ctx, message, final); err != nil {
+			// В URL запроса токен бота, в сообщение об ошибке он попасть не должен
+			s.logger.reportError("telegram alert error: %v", strings.Replace(err.Error(), s.token, RedactMask, -1))
+		}
+	}
+}
+
+// splitMessage разбивает текст на части не длиннее max символов, по возможности - по строкам
+func splitMessage(text string, max int) (parts []string) {
+	for utf8.RuneCountInString(text) > max {
+		cut, count := 0, 0
+		for i := range text {
+			if count == max {
+				cut = i
+				break
+			}
+			count++
+		}
+		// Не режем по строке, если от сообщения остается меньше половины
+		if nl := strings.LastIndexByte(text[:cut], '\n'); nl > cut/2 {
+			cut = nl + 1
+		}
+		parts = append(parts, text[:cut])
+		text = text[cut:]
+	}
+	if strings.TrimSpace(text) != "" {
+		parts = append(parts, text)
+	}
+	return
+}
+
+// telegramResponse ответ Bot API
+type telegramResponse struct {
+	OK          bool   `json:"ok"`
+	Description string `json:"description"`
+	Parameters  struct {
+		RetryAfter int `json:"retry_after"`
+	} `json:"parameters"`
+}
+
+// send отправляет одно сообщение, выдерживая TelegramMinInterval и retry_after из ответа 429
+//	* ctx	- context.Context, ограничивает время запроса
+//	* text	- string
+//	* once	- bool, без ожидания и повторов
+func (s *TelegramSink) send(ctx context.Context, text string, once bool) error {
+	form := url.Values{
+		"chat_id":                  {s.chatID},
+		"text":                     {text},
+		"disable_web_page_preview": {"true"},
+	}.Encode()
+	for attempt := 0; attempt < 3; attempt++ {
+		if wait := s.interval - time.Since(s.lastSent); wait > 0 && !once {
+			time.Sleep(wait)
+		}
+		s.lastSent = time.Now()
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.apiURL, strings.NewReader(form))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		resp, err := s.client.Do(req)
+		if urlErr, ok := err.(*url.Error); ok {
+			return fmt.Errorf("sendMessage: %v", urlErr.Err)
+		} else if err != nil {
+			return err
+		}
+		var result telegramResponse
+		body, err := ioutil.ReadAll(io.LimitReader(resp.Body, 64*1024))
+		resp.Body.Close()
+		if err == nil {
+			err = json.Unmarshal(body, &result)
+		}
+		if err == nil && result.OK {
+			return nil
+		}
+		if resp.StatusCode != http.StatusTooManyRequests || once {
+			return fmt.Errorf("sendMessage: %v %v", resp.Status, result.Description)
+		}
+		retryAfter := time.Duration(result.Parameters.RetryAfter) * time.Second
+		if retryAfter > telegramMaxRetryAfter {
+			retryAfter = telegramMaxRetryAfter
+		}
+		time.Sleep(retryAfter)
+	}
+	return fmt.Errorf("sendMessage: too many requests")
+}
+
+// Close отправляет оставшиеся записи и останавливает отправку
+func (s *TelegramSink) Close() error {
+	s.closeOnce.Do(func() {
+		ExitWaitChans.Remove(s.exitWait)
+	})
+	<-s.done
+	return nil
+}
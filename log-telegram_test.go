@@ -0,0 +1,111 @@
+package common
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestTelegramSink(t *testing.T) {
+	var mutex sync.Mutex
+	var messages []string
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		requests++
+		if r.URL.Path != "/botTOKEN/sendMessage" || r.FormValue("chat_id") != "-100" {
+			http.Error(w, `{"ok":false,"description":"bad request"}`, http.StatusBadRequest)
+			return
+		}
+		if requests == 1 {
+			w.WriteHeader(http.StatusTooManyRequests)
+			w.Write([]byte(`{"ok":false,"description":"Too Many Requests","parameters":{"retry_after":0}}`))
+			return
+		}
+		messages = append(messages, r.FormValue("text"))
+		w.Write([]byte(`{"ok":true}`))
+	}))
+	defer server.Close()
+
+	sink := NewTelegramSink("TOKEN", "-100", TelegramBaseURL(server.URL), TelegramBatchWindow(20*time.Millisecond), TelegramMinInterval(0))
+	l, _ := newTestLogger(LevelDebug)
+	l.AddWriter(sink, LevelError)
+	l.Warn("not sent")
+	l.Error("first")
+	l.Named("pdg").Error("second")
+	time.Sleep(200 * time.Millisecond)
+	l.Error(strings.Repeat("x", 5000))
+	sink.Close()
+
+	mutex.Lock()
+	defer mutex.Unlock()
+	if len(messages) != 3 || strings.Contains(messages[0], "not sent") ||
+		!strings.Contains(messages[0], "] first\n") || !strings.Contains(messages[0], "] pdg: second\n") {
+		t.Fatalf("unexpected messages: %q", messages)
+	}
+	if len([]rune(messages[1])) != telegramMaxMessage || len(messages[2]) == 0 {
+		t.Fatalf("long message must be split: %v, %v", len(messages[1]), len(messages[2]))
+	}
+	if err := sink.WriteRecord(&Record{Message: "after close"}); err == nil {
+		t.Fatal("closed sink must return an error")
+	}
+}
+
+func TestTelegramSinkErrors(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	server.Close()
+	token := "123456789:AAHdqTcvCH1vGWJxfSeofSAs0K5PALDsawQ"
+	l, _ := newTestLogger(LevelDebug)
+	var fallback bytes.Buffer
+	l.SetFallbackWriter(&fallback)
+	sink := NewTelegramSink(token, "-100", TelegramBaseURL(server.URL), TelegramBatchWindow(time.Millisecond), TelegramMinInterval(0), l)
+	l.AddWriter(sink, LevelError)
+	l.Error("unsent")
+	sink.Close()
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if !strings.Contains(fallback.String(), "telegram alert error: sendMessage: ") || strings.Contains(fallback.String(), token) {
+		t.Fatalf("unexpected reported error: %q", fallback.String())
+	}
+}
+
+func TestTelegramSinkExitTimeout(t *testing.T) {
+	release := make(chan struct{})
+	hanging := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer hanging.Close()
+	defer close(release)
+	limited := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTooManyRequests)
+		w.Write([]byte(`{"ok":false,"description":"Too Many Requests","parameters":{"retry_after":30}}`))
+	}))
+	defer limited.Close()
+
+	for _, server := range []*httptest.Server{hanging, limited} {
+		l, _ := newTestLogger(LevelDebug)
+		var fallback bytes.Buffer
+		l.SetFallbackWriter(&fallback)
+		sink := NewTelegramSink("TOKEN", "-100", TelegramBaseURL(server.URL), TelegramBatchWindow(time.Hour),
+			TelegramExitTimeout(100*time.Millisecond), l)
+		l.AddWriter(sink, LevelError)
+		l.Error(strings.Repeat("x", 5000))
+		start := time.Now()
+		sink.Close()
+		if elapsed := time.Since(start); elapsed > 2*time.Second {
+			t.Fatalf("final flush took %v", elapsed)
+		}
+		l.mutex.Lock()
+		reported := fallback.String()
+		l.mutex.Unlock()
+		if !strings.Contains(reported, "telegram alert error: sendMessage: ") {
+			t.Fatalf("unexpected reported error: %q", reported)
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package common
+
+import (
+	"time"
+)
+
+// Форматы времени TextEncoder (см. SetTimeFormat), кроме них можно указать любой layout для time.Format
+const (
+	// TimeDefault YYYY-MM-DD HH:MM:SS.micro
+	TimeDefault = ""
+	// TimeRFC3339 RFC3339 с микросекундами и часовым поясом
+	TimeRFC3339 = "rfc3339"
+	// TimeElapsed время с запуска процесса: +HH:MM:SS.micro
+	TimeElapsed = "elapsed"
+)
+
+// startTime время запуска процесса для TimeElapsed
+var startTime = time.Now()
+
+// appendTime дописывает время в формате layout, для встроенных форматов - без time.Format
+func appendTime(buf *[]byte, t time.Time, layout string) {
+	switch layout {
+	case TimeDefault:
+		appendDate(buf, t, '-')
+		*buf = append(*buf, ' ')
+		appendClock(buf, t)
+	case TimeRFC3339:
+		appendTimestamp(buf, t)
+	case TimeElapsed:
+		appendElapsed(buf, t.Sub(startTime))
+	default:
+		*buf = t.AppendFormat(*buf, layout)
+	}
+}
+
+// appendElapsed дописывает длительность в виде +HH:MM:SS.micro
+func appendElapsed(buf *[]byte, d time.Duration) {
+	if d < 0 {
+		*buf = append(*buf, '-')
+		d = -d
+	} else {
+		*buf = append(*buf, '+')
+	}
+	micro := int(d / time.Microsecond)
+	sec := micro / 1e6
+	itoa(buf, sec/3600, 2)
+	*buf = append(*buf, ':')
+	itoa(buf, sec/60%60, 2)
+	*buf = append(*buf, ':')
+	itoa(buf, sec%60, 2)
+	*buf = append(*buf, '.')
+	itoa(buf, micro%1e6, 6)
+}
+
+// SetTimeFormat устанавливает формат времени текстового формата (см. TextEncoder)
+//	* layout - TimeDefault, TimeRFC3339, TimeElapsed или layout для time.Format
+func (l *Logger) SetTimeFormat(layout string) {
+	l.mutex.Lock()
+	if encoder, ok := l.encoder.(TextEncoder); ok {
+		encoder.TimeLayout = layout
+		l.encoder = encoder
+	}
+	l.mutex.Unlock()
+}
+
+// SetUTC устанавливает выводить ли время записей в UTC (во всех форматах), по умолчанию - местное время
+//	* utc - bool
+func (l *Logger) SetUTC(utc bool) {
+	l.stateMutex.Lock()
+	l.utc = utc
+	l.stateMutex.Unlock()
+}
@@ -1,13 +1,16 @@
 package common
 
 import (
+	"errors"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"os"
 	"path/filepath"
 	"runtime"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -59,7 +62,6 @@ const (
 
 type logType struct {
 	prefix string
-	color  int
 }
 
 var (
@@ -74,15 +76,20 @@ var (
 func init() {
 
 	logTypes = map[logLevels]logType{
-		LevelFatal:   logType{"ftl", fgHiRed},
-		LevelError:   logType{"err", fgHiRed},
-		LevelWarn:    logType{"wrn", fgHiMagenta},
-		LevelInfo:    logType{"inf", fgHiGreen},
-		LevelVerbose: logType{"vrb", fgHiCyan},
-		LevelDebug:   logType{"dbg", fgHiBlue},
+		LevelFatal:   logType{"ftl"},
+		LevelError:   logType{"err"},
+		LevelWarn:    logType{"wrn"},
+		LevelInfo:    logType{"inf"},
+		LevelVerbose: logType{"vrb"},
+		LevelDebug:   logType{"dbg"},
 	}
 
 	Log = NewLogger(os.Stdout, LevelDebug)
+	if spec := os.Getenv(LogLevelEnv); spec != "" {
+		if err := Log.SetLevelSpec(spec); err != nil {
+			Log.Error(err)
+		}
+	}
 
 	// IsMacOs = runtime.GOOS == "darwin"
 	// IsDev = IsMacOs
@@ -90,16 +97,77 @@ func init() {
 	Log.Info("starting...")
 }
 
+// Field именованное значение, которое выводится после сообщения
+type Field struct {
+	Key   string
+	Value interface{}
+}
+
+// RecordWriter writer, которому вместо строки текста передается сама запись (syslog, journald, ...)
+// Запись r используется повторно, сохранять её после возврата из WriteRecord нельзя
+type RecordWriter interface {
+	io.Writer
+	WriteRecord(r *Record) error
+}
+
+// WriterUseColors параметр AddWriter: выводить ли в writer с цветом
+// (по умолчанию - только в терминал, с учетом NO_COLOR и FORCE_COLOR)
+type WriterUseColors bool
+
+// logWriter writer логгера со своим минимальным уровнем и настройкой цветов
+type logWriter struct {
+	writer    io.Writer
+	level     logLevels
+	useColors bool
+	// writer открыт логгером (SetFileWriter) и закрывается при замене
+	owned bool
+	// последняя запись в writer завершилась ошибкой (см. SetFallbackWriter)
+	failed bool
+}
+
+// loggerCore общее состояние логгера и всех его дочерних логгеров
+type loggerCore struct {
+	// counts первым полем для выравнивания 64-битных счетчиков (см. MetricsHandler)
+	counts      levelCounters
+	out         []logWriter
+	level       int32 // logLevels, доступ только через atomic
+	stackLevel  int32 // logLevels, доступ только через atomic
+	mutex       sync.Mutex
+	buf         []byte
+	colorBuf    []byte
+	record      Record
+	encoder     Encoder
+	fallback    io.Writer
+	writeErrors map[string]uint64
+	// stateMutex защищает состояние, нужное для формирования записи,
+	// и не захватывается на время вывода в writer'ы
+	stateMutex      sync.Mutex
+	noFilename      int32 // доступ только через atomic
+	funcName        bool
+	utc             bool
+	async           *asyncQueue
+	asyncExitWait   chan WaitChanResult
+	droppedTotal    uint64
+	suppressor      *logSuppressor
+	suppressedTotal uint64
+	hooks           []*logHook
+	hooksDropped    uint64
+	callerCounts    map[callerKey]uint64
+	components      map[string]*logComponent
+	redactor        *logRedactor
+}
+
 // Logger тип
 type Logger struct {
-	out            []io.Writer
-	level          logLevels
-	mutex          sync.Mutex
-	buf            []byte
-	useColors      bool
-	callStackAdder int
-	noFilename     bool
-	customFilename string
+	*loggerCore
+	fields    []Field
+	component *logComponent
+	// skip сколько дополнительных кадров пропустить при определении вызывающего (см. Skip)
+	skip int
+	// at вызывающий, заданный явно (см. At)
+	at *StackFrame
+	// crossed буфер области FingersCrossed
+	crossed *fingersCrossed
 }
 
 // NewLogger Создает новый логгер
@@ -107,7 +175,41 @@ type Logger struct {
 //	* level	- уровень логгинга
 func NewLogger(out io.Writer, level logLevels) *Logger {
 
-	return &Logger{out: []io.Writer{out}, level: level, useColors: true}
+	return &Logger{loggerCore: &loggerCore{out: []logWriter{{writer: out, level: LevelDebug, useColors: detectColors(out)}}, level: int32(level), stackLevel: int32(LevelOff), encoder: TextEncoder{}, fallback: os.Stderr}}
+}
+
+// With возвращает дочерний логгер, который добавляет поля к каждому сообщению
+// Дочерний логгер разделяет с родителем writer'ы и уровень логгинга
+//	* kv - пары ключ, значение (или Field, или типизированные поля Int, String, ...)
+func (l *Logger) With(kv ...interface{}) *Logger {
+	fields := make([]Field, len(l.fields), len(l.fields)+len(kv)/2+1)
+	copy(fields, l.fields)
+	child := *l
+	child.fields = appendFields(fields, kv)
+	return &child
+}
+
+// appendFields разбирает пары ключ, значение в поля
+func appendFields(fields []Field, kv []interface{}) []Field {
+	for i := 0; i < len(kv); i++ {
+		switch v := kv[i].(type) {
+		case Field:
+			fields = append(fields, v)
+		case []Field:
+			fields = append(fields, v...)
+		case TypedField:
+			fields = append(fields, v.field())
+		default:
+			key, ok := v.(string)
+			if !ok || i+1 == len(kv) {
+				fields = append(fields, Field{"!badkey", v})
+				continue
+			}
+			fields = append(fields, Field{key, kv[i+1]})
+			i++
+		}
+	}
+	return fields
 }
 
 func itoa(buf *[]byte, i int, width int) {
@@ -125,125 +227,236 @@ func itoa(buf *[]byte, i int, width int) {
 	*buf = append(*buf, b[bp:]...)
 }
 
-func (l *Logger) writeToOut(level logLevels, message string) {
+func (l *Logger) writeToOut(level logLevels, message string, fields []Field, err error) {
 
 	now := time.Now()
-	logType := logTypes[level]
-
-	_, file, line, ok := runtime.Caller(3 + l.callStackAdder)
-	// pc, file, line, ok := runtime.Caller(3 + l.callStackAdder)
-	// fn := runtime.FuncForPC(pc)
 
-	l.mutex.Lock()
-	defer l.mutex.Unlock()
-
-	l.buf = l.buf[:0]
-	if l.useColors {
-		l.buf = append(l.buf, "\x1b[1;"...)
-		itoa(&l.buf, logType.color, 2)
-		l.buf = append(l.buf, 'm')
-	}
-
-	l.buf = append(l.buf, "    "...)
-	l.buf = append(l.buf, logType.prefix...)
-
-	l.buf = append(l.buf, ' ')
-
-	year, month, day := now.Date()
-	itoa(&l.buf, year, 4)
-	l.buf = append(l.buf, '-')
-	itoa(&l.buf, int(month), 2)
-	l.buf = append(l.buf, '-')
-	itoa(&l.buf, day, 2)
-	l.buf = append(l.buf, ' ')
-
-	hour, min, sec := now.Clock()
-	itoa(&l.buf, hour, 2)
-	l.buf = append(l.buf, ':')
-	itoa(&l.buf, min, 2)
-	l.buf = append(l.buf, ':')
-	itoa(&l.buf, sec, 2)
-	l.buf = append(l.buf, '.')
-	itoa(&l.buf, now.Nanosecond()/1e3, 6)
-
-	if !l.noFilename {
-		if l.customFilename != "" {
-			l.buf = append(l.buf, " ["...)
-			l.buf = append(l.buf, l.customFilename...)
-			l.customFilename = ""
-			l.buf = append(l.buf, "]"...)
-		} else if ok {
-			l.buf = append(l.buf, " ["...)
-			l.buf = append(l.buf, filepath.Base(file)...)
-			l.buf = append(l.buf, ':')
-			itoa(&l.buf, line, -1)
-			// if fn != nil {
-			// 	l.buf = append(l.buf, ':')
-			// 	l.buf = append(l.buf, fn.Name()...)
-			// }
-			l.buf = append(l.buf, "]"...)
+	skip := l.skip
+	r := Record{Time: now, Level: level, Name: l.Name(), Message: message, Fields: fields, Err: err}
+	if level >= l.getStackLevel() {
+		r.Stack = stackFor(err, 3+skip)
+	}
+	if l.at != nil {
+		r.File = l.at.File
+		r.Line = l.at.Line
+		r.Func = l.at.Func
+	} else if atomic.LoadInt32(&l.noFilename) == 0 {
+		var pcs [1]uintptr
+		if runtime.Callers(4+skip, pcs[:]) > 0 {
+			frame, _ := runtime.CallersFrames(pcs[:]).Next()
+			r.PC = pcs[0]
+			r.File = frame.File
+			r.Line = frame.Line
+			r.Func = frame.Function
 		}
 	}
+	l.output(&r)
+}
 
-	if l.useColors {
-		l.buf = append(l.buf, "\x1b[0m"...)
+// output выводит сформированную запись с учетом FingersCrossed, SetNoFileName, SetFuncName, Redact и подавления повторов
+//	* r - *Record
+func (l *Logger) output(r *Record) {
+	if l.crossed != nil && !l.crossed.pass(l, r) {
+		return
+	}
+	l.stateMutex.Lock()
+	if l.utc {
+		r.Time = r.Time.UTC()
+	}
+	if atomic.LoadInt32(&l.noFilename) != 0 {
+		r.File = ""
+		r.Line = 0
+		r.PC = 0
+		r.Func = ""
+	} else if !l.funcName {
+		r.Func = ""
+	}
+	l.countRecord(r)
+	suppressor := l.suppressor
+	redactor := l.redactor
+	l.stateMutex.Unlock()
+
+	r.Fields = maskFields(r.Fields)
+	if redactor != nil {
+		redactor.redact(r)
 	}
-	l.buf = append(l.buf, ' ')
-	l.buf = append(l.buf, message...)
-	l.buf = append(l.buf, '\n')
-
-	// Если ошибка - нарисуем стек
-	// if level == LevelError {
-
-	// 	level := 3
-	// 	for {
 
-	// 		_, file, line, ok = runtime.Caller(level)
-	// 		if !ok {
+	if suppressor != nil {
+		allowed, summaries := suppressor.allow(r)
+		for _, summary := range summaries {
+			l.emit(summary)
+		}
+		if !allowed {
+			return
+		}
+	}
+	l.emit(r)
+}
 
-	// 			break
-	// 		}
+// emit выводит запись через буфер асинхронного логгера или сразу
+//	* r - *Record
+func (l *Logger) emit(r *Record) {
+	l.stateMutex.Lock()
+	async := l.async
+	hooks := l.hooks
+	l.stateMutex.Unlock()
+	if len(hooks) > 0 {
+		runHooks(hooks, r)
+	}
+	if async != nil && async.push(r) {
+		return
+	}
 
-	// 		l.buf = append(l.buf, "\t\t"...)
-	// 		l.buf = append(l.buf, "Called from "...)
-	// 		l.buf = append(l.buf, file...)
-	// 		l.buf = append(l.buf, ':')
-	// 		itoa(&l.buf, line, -1)
-	// 		l.buf = append(l.buf, '\n')
+	l.mutex.Lock()
+	l.record = *r
+	l.write(&l.record)
+	l.mutex.Unlock()
+}
 
-	// 		level++
-	// 	}
-	// }
+// write кодирует и выводит запись во все writer'ы, l.mutex должен быть захвачен
+//	* r - *Record
+func (l *Logger) write(r *Record) {
+	l.buf = l.buf[:0]
+	l.colorBuf = l.colorBuf[:0]
 
-	for idx, writer := range l.out {
-		if writer != nil {
-			if _, err := writer.Write(l.buf); err != nil {
-				if err != os.ErrClosed {
-					fmt.Printf("log write error: %v\n", err)
-				}
-				l.out[idx] = nil
+	for idx, out := range l.out {
+		if out.writer == nil || r.Level < out.level {
+			continue
+		}
+		var err error
+		if recordWriter, ok := out.writer.(RecordWriter); ok {
+			err = recordWriter.WriteRecord(r)
+		} else {
+			// Запись кодируется не больше двух раз: с цветом и без
+			buf := &l.buf
+			if out.useColors {
+				buf = &l.colorBuf
 			}
+			if len(*buf) == 0 {
+				*buf = l.encoder.Encode(*buf, r, out.useColors)
+			}
+			_, err = out.writer.Write(*buf)
+		}
+		if err != nil {
+			l.writeFallback(idx, r, err)
+		} else {
+			l.out[idx].failed = false
 		}
 	}
+	// Не держим ссылки на поля, стек и ошибку до следующей записи
+	*r = Record{}
+}
+
+// writeFallback выводит в запасной writer запись, которую не удалось вывести в writer idx,
+// и, один раз за серию ошибок, саму ошибку. Закрытый writer удаляется. l.mutex должен быть захвачен
+//	* idx	- индекс writer'а в l.out
+//	* r		- *Record
+//	* err	- ошибка записи
+func (l *Logger) writeFallback(idx int, r *Record, err error) {
+	out := &l.out[idx]
+	fallback := l.fallback
+	if fallback == nil || fallback == out.writer {
+		fallback = ioutil.Discard
+	}
+	if !out.failed {
+		fmt.Fprintf(fallback, "log write error (%T): %v\n", out.writer, err)
+	}
+	if l.writeErrors == nil {
+		l.writeErrors = make(map[string]uint64, 2)
+	}
+	l.writeErrors[fmt.Sprintf("%T", out.writer)]++
+	out.failed = true
+	if errors.Is(err, os.ErrClosed) {
+		out.writer = nil
+	}
+	if len(l.buf) == 0 {
+		l.buf = l.encoder.Encode(l.buf, r, false)
+	}
+	fallback.Write(l.buf)
+}
+
+// reportError выводит в запасной writer (см. SetFallbackWriter) ошибку, которую некуда вывести,
+// например ошибку отправки из writer'а, с маскировкой секретов (см. Redact)
+//	* format	- string
+//	* args		- ...interface{}
+func (l *Logger) reportError(format string, args ...interface{}) {
+	message := fmt.Sprintf(format, args...)
+	l.stateMutex.Lock()
+	redactor := l.redactor
+	l.stateMutex.Unlock()
+	if redactor != nil {
+		message = redactor.redactString(message)
+	}
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if l.fallback != nil {
+		io.WriteString(l.fallback, message+"\n")
+	}
 }
 
 // log вывести сообщение уровня level
+// Строка с % в первом аргументе считается форматом (для совместимости), go vet такие вызовы
+// не проверяет - для форматирования лучше использовать Infof и т.п.
 //	* level	- logLevels
-//  * s			- ...interface{}
+//  * s			- ...interface{}, аргументы func() string вычисляются, только если уровень включен
 func (l *Logger) log(level logLevels, s ...interface{}) {
-	if l.level <= level && len(s) > 0 {
+	if len(s) > 0 && l.enabled(level) {
+		err := findError(s, l.fields)
+		s = maskArgs(resolveLazy(s))
 		if first, ok := s[0].(string); ok && strings.Contains(first, "%") && len(s) > 1 {
-			l.writeToOut(level, fmt.Sprintf(first, s[1:]...))
+			l.writeToOut(level, fmt.Sprintf(first, s[1:]...), l.fields, err)
 		} else {
-			l.writeToOut(level, fmt.Sprint(s...))
+			l.writeToOut(level, fmt.Sprint(s...), l.fields, err)
 		}
 	}
 }
 
-// Print вывести сообщение уровня l.level
+// logf вывести сообщение уровня level по формату
+//	* level		- logLevels
+//	* format	- string
+//	* args		- ...interface{}, аргументы func() string вычисляются, только если уровень включен
+func (l *Logger) logf(level logLevels, format string, args ...interface{}) {
+	if l.enabled(level) {
+		l.writeToOut(level, sprintf(format, args...), l.fields, findError(args, l.fields))
+	}
+}
+
+// sprintf форматирует сообщение, аргументы без секретов и func() string передаются в fmt как есть
+func sprintf(format string, args ...interface{}) string {
+	if prepared := maskArgs(resolveLazy(args)); len(prepared) > 0 && &prepared[0] != &args[0] {
+		return fmt.Sprintf(format, prepared...)
+	}
+	return fmt.Sprintf(format, args...)
+}
+
+// findError возвращает первую ошибку среди аргументов и полей
+func findError(s []interface{}, fields []Field) error {
+	for _, arg := range s {
+		if err, ok := arg.(error); ok {
+			return err
+		}
+	}
+	for _, field := range fields {
+		if err, ok := field.Value.(error); ok {
+			return err
+		}
+	}
+	return nil
+}
+
+// Print вывести сообщение текущего уровня логгера
 //  * s	- ...interface{}
 func (l *Logger) Print(s ...interface{}) {
-	l.log(l.level, fmt.Sprint(s...))
+	l.print(l.getLevel(), s...)
+}
+
+// print вывести сообщение уровня level, аргументы объединяются как в fmt.Sprint, без формата
+//	* level	- logLevels
+//	* s		- ...interface{}
+func (l *Logger) print(level logLevels, s ...interface{}) {
+	if l.enabled(level) {
+		l.writeToOut(level, fmt.Sprint(maskArgs(resolveLazy(s))...), l.fields, findError(s, l.fields))
+	}
 }
 
 // Verbose вывести сообщение уровня LevelVerbose
@@ -290,51 +503,178 @@ func (l *Logger) Fatal(s ...interface{}) {
 	Exit(-1)
 }
 
+// Verbosef вывести сообщение уровня LevelVerbose по формату
+//	* format	- string
+//	* args		- ...interface{}
+func (l *Logger) Verbosef(format string, args ...interface{}) {
+
+	l.logf(LevelVerbose, format, args...)
+}
+
+// Debugf вывести сообщение уровня LevelDebug по формату
+//	* format	- string
+//	* args		- ...interface{}
+func (l *Logger) Debugf(format string, args ...interface{}) {
+
+	l.logf(LevelDebug, format, args...)
+}
+
+// Infof вывести сообщение уровня LevelInfo по формату
+//	* format	- string
+//	* args		- ...interface{}
+func (l *Logger) Infof(format string, args ...interface{}) {
+
+	l.logf(LevelInfo, format, args...)
+}
+
+// Warnf вывести сообщение уровня LevelWarn по формату
+//	* format	- string
+//	* args		- ...interface{}
+func (l *Logger) Warnf(format string, args ...interface{}) {
+
+	l.logf(LevelWarn, format, args...)
+}
+
+// Errorf вывести сообщение уровня LevelError по формату
+//	* format	- string
+//	* args		- ...interface{}
+func (l *Logger) Errorf(format string, args ...interface{}) {
+
+	l.logf(LevelError, format, args...)
+}
+
+// Fatalf вывести сообщение уровня LevelFatal по формату и завершиться
+//	* format	- string
+//	* args		- ...interface{}
+func (l *Logger) Fatalf(format string, args ...interface{}) {
+
+	l.logf(LevelFatal, format, args...)
+	Exit(-1)
+}
+
+// FatalGo выводит сообщение уровня LevelFatal и завершается в отдельной горутине
+// Вызывающим считается место вызова FatalGo
+//  * s	- ...interface{}
 func (l *Logger) FatalGo(s ...interface{}) {
-	custom := ""
-	_, file, line, ok := runtime.Caller(l.callStackAdder + 1)
-	if ok {
-		custom = fmt.Sprintf("%v:%v", filepath.Base(file), line)
+	caller := l
+	if l.at == nil {
+		var pcs [1]uintptr
+		if runtime.Callers(2+l.skip, pcs[:]) > 0 {
+			frame, _ := runtime.CallersFrames(pcs[:]).Next()
+			caller = l.withCaller(StackFrame{Func: frame.Function, File: frame.File, Line: frame.Line})
+		}
 	}
-	go func() {
-		l.customFilename = custom
-		l.Fatal(s...)
-	}()
+	go caller.Fatal(s...)
 }
 
 // SetLogLevel устанавливает уровень логгинга
+// Для именованного логгера (см. Named) - только его уровень
 //	* level - logLevels
 func (l *Logger) SetLogLevel(level logLevels) {
 
-	l.level = level
+	atomic.StoreInt32(l.levelPtr(), int32(level))
 }
 
+// SetCallStackAdder устанавливает, сколько дополнительных кадров пропускать при определении вызывающего
+// Действует только на l и логгеры, созданные из него после вызова; вызывать до того, как l используется
+// из нескольких горутин
+//
+// Deprecated: используйте Skip, который возвращает отдельный логгер для каждой обертки
+//	* adder - int
 func (l *Logger) SetCallStackAdder(adder int) {
-	l.callStackAdder = adder
+	l.skip = adder
 }
 
+// SetNoFileName устанавливает не выводить вызывающего
+//	* no - bool
 func (l *Logger) SetNoFileName(no bool) {
-	l.noFilename = no
+	var value int32
+	if no {
+		value = 1
+	}
+	atomic.StoreInt32(&l.noFilename, value)
 }
 
 // SetWriter устанавливает новый writer для логгера
+// Цвет включается, только если writer - терминал (с учетом NO_COLOR и FORCE_COLOR)
 //	* writer - io.Writer
 func (l *Logger) SetWriter(writer io.Writer) {
-	l.out[0] = writer
+	l.mutex.Lock()
+	prev := l.out[0]
+	l.out[0].writer = writer
+	l.out[0].owned = false
+	l.out[0].useColors = detectColors(writer)
+	l.mutex.Unlock()
+	if prev.owned && prev.writer != writer {
+		prev.writer.(io.Closer).Close()
+	}
+}
+
+// SetFallbackWriter устанавливает запасной writer (по умолчанию os.Stderr), в который выводятся
+// ошибки записи в writer'ы логгера и записи, которые не удалось вывести
+//	* writer - io.Writer, nil - не выводить
+func (l *Logger) SetFallbackWriter(writer io.Writer) {
+	l.mutex.Lock()
+	l.fallback = writer
+	l.mutex.Unlock()
 }
 
 func (l *Logger) GetWriter() io.Writer {
-	return l.out[0]
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	return l.out[0].writer
 }
 
 // AddWriter добавляет writer для логгера
 //	* writer - io.Writer
-func (l *Logger) AddWriter(writer io.Writer) {
+//	* params - logLevels (минимальный уровень для writer'а), WriterUseColors
+func (l *Logger) AddWriter(writer io.Writer, params ...interface{}) {
+	out := logWriter{writer: writer, level: LevelDebug, useColors: detectColors(writer)}
+	for _, param := range params {
+		switch param := param.(type) {
+		case logLevels:
+			out.level = param
+		case WriterUseColors:
+			out.useColors = bool(param)
+		}
+	}
 	l.mutex.Lock()
-	l.out = append(l.out, writer)
+	l.out = append(l.out, out)
 	l.mutex.Unlock()
 }
 
+// SetWriterLevel устанавливает минимальный уровень для writer'а
+// Возвращает false, если writer не найден
+//	* writer	- io.Writer
+//	* level		- logLevels
+func (l *Logger) SetWriterLevel(writer io.Writer, level logLevels) (found bool) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	for i := range l.out {
+		if l.out[i].writer == writer {
+			l.out[i].level = level
+			found = true
+		}
+	}
+	return
+}
+
+// SetWriterUseColors устанавливает использовать ли цвета при выводе в writer
+// Возвращает false, если writer не найден
+//	* writer		- io.Writer
+//	* useColors	- bool
+func (l *Logger) SetWriterUseColors(writer io.Writer, useColors bool) (found bool) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	for i := range l.out {
+		if l.out[i].writer == writer {
+			l.out[i].useColors = useColors
+			found = true
+		}
+	}
+	return
+}
+
 // RemoveWriter удаляет writer логгера
 //	* writer - io.Writer
 func (l *Logger) RemoveWriter(toRemove io.Writer) (found bool) {
@@ -345,7 +685,7 @@ func (l *Logger) RemoveWriter(toRemove io.Writer) (found bool) {
 		if i == 0 {
 			continue
 		}
-		if l.out[i] == toRemove {
+		if l.out[i].writer == toRemove {
 			found = true
 		} else {
 			if i != newLen {
@@ -361,30 +701,84 @@ func (l *Logger) RemoveWriter(toRemove io.Writer) (found bool) {
 
 // SetFileWriter устанавливает новый writer для логгера, который пишет в файл
 // WARN: автоматически выключает вывод с цветом, чтобы включить - использовать (*Logger)SetUseColors(true)
-//	* fileName - string
+//	* fileName	- string
+//	* params	- параметры ротации, см. NewRotatingFile
+//
 // Если ошибка - возвращает ошибку, иначе nil
-func (l *Logger) SetFileWriter(fileName string) error {
+func (l *Logger) SetFileWriter(fileName string, params ...interface{}) error {
 
-	f, err := os.OpenFile(fileName, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	f, err := NewRotatingFile(fileName, params...)
 	if err != nil {
 
 		return err
 	}
 
-	// AtExit(func() {
+	l.mutex.Lock()
+	prev := l.out[0]
+	l.out[0] = logWriter{writer: f, level: prev.level, owned: true}
+	l.mutex.Unlock()
+	if prev.owned {
+		prev.writer.(io.Closer).Close()
+	}
+	return nil
+}
 
-	// 	f.Close()
-	// })
+// Reopener writer, который умеет заново открывать свой файл (см. HandleSighup)
+type Reopener interface {
+	Reopen() error
+}
 
-	l.out[0] = f
-	l.SetUseColors(false)
-	return nil
+// Reopen заново открывает все writer'ы логгера, реализующие Reopener
+// На время переоткрытия запись в лог ожидает, поэтому строки не теряются
+func (l *Logger) Reopen() (err error) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	for _, out := range l.out {
+		if reopener, ok := out.writer.(Reopener); ok {
+			if reopenErr := reopener.Reopen(); reopenErr != nil && err == nil {
+				err = reopenErr
+			}
+		}
+	}
+	return
 }
 
-// SetUseColors устанавливает использовать ли цвета при выводе или нет
+// Close закрывает все writer'ы логгера, реализующие io.Closer (кроме os.Stdout и os.Stderr)
+// Вызывается в Exit
+func (l *Logger) Close() (err error) {
+	l.stopSuppressor()
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	for idx, out := range l.out {
+		if out.writer == os.Stdout || out.writer == os.Stderr {
+			continue
+		}
+		if closer, ok := out.writer.(io.Closer); ok {
+			if closeErr := closer.Close(); closeErr != nil && err == nil {
+				err = closeErr
+			}
+			l.out[idx].writer = nil
+			l.out[idx].owned = false
+		}
+	}
+	return
+}
+
+// SetEncoder устанавливает формат вывода логгера: TextEncoder{}, JSONEncoder{} или LogfmtEncoder{}
+//	* encoder - Encoder
+func (l *Logger) SetEncoder(encoder Encoder) {
+	l.mutex.Lock()
+	l.encoder = encoder
+	l.mutex.Unlock()
+}
+
+// SetUseColors устанавливает использовать ли цвета при выводе в основной writer или нет
+// Для writer'ов, добавленных через AddWriter, - см. SetWriterUseColors
 func (l *Logger) SetUseColors(useColors bool) {
 
-	l.useColors = useColors
+	l.mutex.Lock()
+	l.out[0].useColors = useColors
+	l.mutex.Unlock()
 }
 
 func AddFileAndLine(err error) error {
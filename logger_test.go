@@ -0,0 +1,746 @@
+package common
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func newTestLogger(level logLevels) (*Logger, *bytes.Buffer) {
+	buf := &bytes.Buffer{}
+	l := NewLogger(buf, level)
+	l.SetUseColors(false)
+	return l, buf
+}
+
+func TestLoggerWith(t *testing.T) {
+	l, buf := newTestLogger(LevelDebug)
+	child := l.With("chat_id", 42, "user", "john doe")
+	child.With("update", 7).Infof("handled %v", "ok")
+	line := buf.String()
+	if !strings.HasSuffix(line, ` handled ok chat_id=42 user="john doe" update=7`+"\n") {
+		t.Fatalf("unexpected line: %q", line)
+	}
+	buf.Reset()
+	child.Info("second")
+	if strings.Contains(buf.String(), "update=") {
+		t.Fatalf("fields leaked from a sibling logger: %q", buf.String())
+	}
+	buf.Reset()
+	l.SetLogLevel(LevelWarn)
+	child.Info("hidden")
+	if buf.Len() != 0 {
+		t.Fatalf("child does not share level with parent: %q", buf.String())
+	}
+}
+
+func TestLoggerEncoders(t *testing.T) {
+	l, buf := newTestLogger(LevelDebug)
+	l.SetEncoder(JSONEncoder{})
+	l.With("chat_id", int64(42), "err", fmt.Errorf("bad \"thing\"")).Warn("line\nbreak")
+	var decoded map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil {
+		t.Fatalf("invalid json %q: %v", buf.String(), err)
+	}
+	if decoded["level"] != "wrn" || decoded["msg"] != "line\nbreak" || decoded["chat_id"] != float64(42) ||
+		decoded["err"] != `bad "thing"` || !strings.HasPrefix(decoded["caller"].(string), "logger_test.go:") {
+		t.Fatalf("unexpected json: %q", buf.String())
+	}
+
+	buf.Reset()
+	l.SetEncoder(LogfmtEncoder{})
+	l.With("user", "john doe").Info("hello")
+	line := buf.String()
+	if !strings.HasPrefix(line, "time=") || !strings.Contains(line, " level=inf caller=logger_test.go:") ||
+		!strings.HasSuffix(line, ` msg=hello user="john doe"`+"\n") {
+		t.Fatalf("unexpected logfmt: %q", line)
+	}
+}
+
+func TestLoggerWriterLevels(t *testing.T) {
+	l, debugBuf := newTestLogger(LevelDebug)
+	warnBuf, errBuf := &bytes.Buffer{}, &bytes.Buffer{}
+	l.AddWriter(warnBuf, LevelWarn, WriterUseColors(true))
+	l.AddWriter(errBuf, LevelError)
+	l.Debug("debug")
+	l.Warn("warn")
+	l.Error("error")
+	if n := strings.Count(debugBuf.String(), "\n"); n != 3 || strings.Contains(debugBuf.String(), "\x1b[") {
+		t.Fatalf("unexpected debug output: %q", debugBuf.String())
+	}
+	if n := strings.Count(warnBuf.String(), "\n"); n != 2 || !strings.Contains(warnBuf.String(), "\x1b[") {
+		t.Fatalf("unexpected warn output: %q", warnBuf.String())
+	}
+	if n := strings.Count(errBuf.String(), "\n"); n != 1 || !strings.Contains(errBuf.String(), " error\n") {
+		t.Fatalf("unexpected error output: %q", errBuf.String())
+	}
+	if !l.RemoveWriter(warnBuf) {
+		t.Fatal("writer not removed")
+	}
+	l.Error("again")
+	if n := strings.Count(warnBuf.String(), "\n"); n != 2 {
+		t.Fatalf("removed writer still written: %q", warnBuf.String())
+	}
+}
+
+func TestLoggerNamedLevels(t *testing.T) {
+	l, buf := newTestLogger(LevelDebug)
+	if err := l.SetLevelSpec("info,pdg=debug,watch=warn"); err != nil {
+		t.Fatal(err)
+	}
+	pdg, backup, watch := l.Named("pdg"), l.Named("pdg").Named("backup"), l.Named("watch")
+	l.Debug("root debug")
+	pdg.Debug("pdg debug")
+	backup.Debug("backup debug")
+	watch.Info("watch info")
+	watch.With("path", "a.go").Warn("watch warn")
+	out := buf.String()
+	if strings.Contains(out, "root debug") || strings.Contains(out, "watch info") ||
+		!strings.Contains(out, " pdg: pdg debug\n") || !strings.Contains(out, " pdg.backup: backup debug\n") ||
+		!strings.Contains(out, " watch: watch warn path=a.go\n") {
+		t.Fatalf("unexpected output: %q", out)
+	}
+	if err := l.SetLevelSpec("info,pdg=loud"); err == nil ||
+		err.Error() != `in log level spec "info,pdg=loud": unknown log level "loud"` {
+		t.Fatalf("invalid spec: %v", err)
+	}
+	if level, err := ParseLevel("WARNING"); err != nil || level != LevelWarn {
+		t.Fatalf("ParseLevel: %v, %v", level, err)
+	}
+	if level, err := ParseLevel(""); err == nil {
+		t.Fatalf("empty level accepted: %v", level)
+	}
+	if err := l.SetLevelSpec("info,pdg="); err == nil {
+		t.Fatal("empty component level accepted")
+	}
+}
+
+func TestRegisterLogFlags(t *testing.T) {
+	defer func() { logLevelFlag = "" }()
+	fs := flag.NewFlagSet("bot", flag.ContinueOnError)
+	RegisterLogFlags(fs)
+	// Повторная регистрация в другом наборе флагов не конфликтует с первой
+	RegisterLogFlags(flag.NewFlagSet("other", flag.ContinueOnError))
+	if err := fs.Parse([]string{"-log-level", "pdg=warn"}); err != nil {
+		t.Fatal(err)
+	}
+	l, _ := newTestLogger(LevelDebug)
+	if err := l.ApplyConfig(LogConfig{Level: "info,pdg=debug"}); err != nil {
+		t.Fatal(err)
+	}
+	if l.getLevel() != LevelInfo || l.Named("pdg").getLevel() != LevelWarn {
+		t.Fatalf("levels: %v", l.Levels())
+	}
+}
+
+func TestLoggerLevelsHandler(t *testing.T) {
+	l, _ := newTestLogger(LevelInfo)
+	handler := l.LevelsHandler()
+	request := func(method, target, body string) string {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(method, target, strings.NewReader(body)))
+		return strings.TrimSpace(rec.Body.String())
+	}
+	if spec := request(http.MethodPost, "/?spec=pdg=debug", ""); spec != "info,pdg=debug" {
+		t.Fatalf("unexpected spec %q", spec)
+	}
+	if spec := request(http.MethodPut, "/", "warn,pdg=inherit,watch=error"); spec != "warn,watch=error" {
+		t.Fatalf("unexpected spec %q", spec)
+	}
+	if level := l.StepLevel(-1); level != LevelInfo || l.Named("pdg").getLevel() != LevelInfo {
+		t.Fatalf("unexpected level after step: %v", level)
+	}
+	if spec := request(http.MethodGet, "/", ""); spec != "info,watch=error" {
+		t.Fatalf("unexpected spec %q", spec)
+	}
+
+	// Форма из curl -d, пустая спецификация
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader("spec=debug%2Cwatch%3Dinherit"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	handler.ServeHTTP(rec, req)
+	if spec := strings.TrimSpace(rec.Body.String()); spec != "debug" {
+		t.Fatalf("unexpected spec %q", spec)
+	}
+	for _, body := range []string{"", "spec="} {
+		rec = httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body)))
+		if rec.Code != http.StatusBadRequest {
+			t.Fatalf("empty spec %q: unexpected status %v", body, rec.Code)
+		}
+	}
+
+	l.SetLogLevel(LevelOff)
+	if level := l.StepLevel(-1); level != LevelError {
+		t.Fatalf("unexpected level after step from off: %v", level)
+	}
+	l.SetLogLevel(LevelOff)
+	if level := l.StepLevel(1); level != LevelFatal {
+		t.Fatalf("unexpected level after step from off: %v", level)
+	}
+}
+
+func newStackError() error {
+	return Errorf("created here")
+}
+
+func TestLoggerStackTraces(t *testing.T) {
+	l, buf := newTestLogger(LevelDebug)
+	l.SetStackTraceLevel(LevelError)
+	quiet := l.Named("quiet")
+	quiet.SetStackTraceLevel(LevelOff)
+
+	l.Warn("no stack")
+	quiet.Error("no stack either")
+	if strings.Contains(buf.String(), "Called from") {
+		t.Fatalf("unexpected stack: %q", buf.String())
+	}
+
+	buf.Reset()
+	l.Error("with stack")
+	lines := strings.Split(buf.String(), "\n")
+	if len(lines) < 3 || !strings.HasPrefix(lines[1], "\t\tCalled from ") ||
+		!strings.HasSuffix(lines[1], "(github.com/bots-for-me/common.TestLoggerStackTraces)") ||
+		strings.Contains(buf.String(), "runtime.") {
+		t.Fatalf("unexpected stack: %q", buf.String())
+	}
+
+	SetErrorStackTraces(true)
+	defer SetErrorStackTraces(false)
+	buf.Reset()
+	l.SetEncoder(JSONEncoder{})
+	l.Error(Errorf(newStackError()))
+	var decoded struct{ Stack []string }
+	if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil {
+		t.Fatalf("invalid json %q: %v", buf.String(), err)
+	}
+	if len(decoded.Stack) < 2 || !strings.HasPrefix(decoded.Stack[0], "github.com/bots-for-me/common.newStackError ") {
+		t.Fatalf("unexpected stack: %q", buf.String())
+	}
+}
+
+func TestLoggerSuppression(t *testing.T) {
+	l, buf := newTestLogger(LevelDebug)
+	l.SetDedupe(time.Hour)
+	l.SetRateLimit(LevelWarn, 0.001, 2)
+	for i := 0; i < 5; i++ {
+		l.Error("telegram is down")
+		l.Warn("retry ", i)
+	}
+	l.Info("other")
+	if suppressed := l.Suppressed(); suppressed != 7 {
+		t.Fatalf("expected 7 suppressed records, got %v: %q", suppressed, buf.String())
+	}
+	l.Close()
+	out := buf.String()
+	if strings.Count(out, "telegram is down") != 2 || strings.Count(out, " retry ") != 3 ||
+		!strings.Contains(out, " last message repeated 4 times: telegram is down\n") ||
+		!strings.Contains(out, " 3 messages suppressed by rate limit, last: retry 4\n") {
+		t.Fatalf("unexpected output: %q", out)
+	}
+}
+
+func TestLoggerContext(t *testing.T) {
+	l, buf := newTestLogger(LevelDebug)
+	ctx := ContextWithLogger(context.Background(), l)
+	ctx = ContextWithFields(ctx, "update", 7)
+	ctx = ContextWithTraceID(ctx, "abc")
+	l.Named("pdg").InfoCtx(ctx, "put %v", "user")
+	LoggerFromContext(ctx).Warn("downstream")
+	out := buf.String()
+	if !strings.Contains(out, " pdg: put user update=7 trace_id=abc\n") ||
+		!strings.Contains(out, " downstream update=7 trace_id=abc\n") ||
+		!strings.Contains(out, "[logger_test.go:") {
+		t.Fatalf("unexpected output: %q", out)
+	}
+	if LoggerFromContext(context.Background()) != Log {
+		t.Fatal("LoggerFromContext without logger must return Log")
+	}
+}
+
+type failingWriter struct {
+	err error
+}
+
+func (w *failingWriter) Write(p []byte) (int, error) {
+	if w.err != nil {
+		return 0, w.err
+	}
+	return len(p), nil
+}
+
+func TestLoggerFallback(t *testing.T) {
+	l, buf := newTestLogger(LevelDebug)
+	fallback := &bytes.Buffer{}
+	l.SetFallbackWriter(fallback)
+	failing := &failingWriter{err: fmt.Errorf("disk full")}
+	l.AddWriter(failing)
+	l.Info("first")
+	l.Info("second")
+	failing.err = nil
+	l.Info("third")
+	out := fallback.String()
+	if strings.Count(out, "log write error") != 1 || !strings.Contains(out, "disk full") ||
+		!strings.Contains(out, " first\n") || !strings.Contains(out, " second\n") || strings.Contains(out, "third") {
+		t.Fatalf("unexpected fallback output: %q", out)
+	}
+	if !strings.Contains(buf.String(), " third\n") {
+		t.Fatalf("unexpected output: %q", buf.String())
+	}
+	if l.GetWriter() != buf || !l.SetWriterLevel(failing, LevelInfo) {
+		t.Fatal("failing writer must not be removed")
+	}
+}
+
+func TestLoggerStdLog(t *testing.T) {
+	l, buf := newTestLogger(LevelInfo)
+	std := l.Named("std").StdLogger(LevelWarn)
+	std.Printf("deprecated %v", "call")
+	out := buf.String()
+	if !strings.Contains(out, " std: deprecated call\n") || !strings.Contains(out, "[logger_test.go:") ||
+		!strings.HasPrefix(strings.TrimSpace(out), "wrn") {
+		t.Fatalf("unexpected output: %q", out)
+	}
+}
+
+func TestMemoryWriter(t *testing.T) {
+	mem := NewMemoryWriter(3)
+	l := NewLogger(mem, LevelDebug)
+	for i := 1; i <= 4; i++ {
+		l.With("i", i).Infof("message %v", i)
+	}
+	l.Error("failed")
+	records := mem.Records()
+	if len(records) != 3 || mem.Total() != 5 || records[0].Message != "message 3" || records[2].Level != LevelError {
+		t.Fatalf("unexpected records: %+v", records)
+	}
+	if got := mem.Records(LevelError); len(got) != 1 || got[0].Message != "failed" {
+		t.Fatalf("unexpected filtered records: %+v", got)
+	}
+	if !mem.Contains("message 4") || mem.Contains(LevelWarn, "message") {
+		t.Fatal("unexpected Contains result")
+	}
+
+	rec := httptest.NewRecorder()
+	mem.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/log?n=2&q=message", nil))
+	if body := rec.Body.String(); strings.Count(body, "\n") != 2 || !strings.Contains(body, " message 4 i=4\n") {
+		t.Fatalf("unexpected body: %q", body)
+	}
+}
+
+func TestLoggerColors(t *testing.T) {
+	for _, env := range []string{NoColorEnv, ForceColorEnv} {
+		defer os.Setenv(env, os.Getenv(env))
+		os.Unsetenv(env)
+	}
+	buf := &bytes.Buffer{}
+	if detectColors(buf) || NewLogger(buf, LevelDebug).out[0].useColors {
+		t.Fatal("colors must be off for non-terminal writers")
+	}
+	os.Setenv(ForceColorEnv, "1")
+	if !detectColors(buf) {
+		t.Fatal("FORCE_COLOR must enable colors")
+	}
+	os.Setenv(NoColorEnv, "1")
+	if detectColors(os.Stdout) {
+		t.Fatal("NO_COLOR must disable colors")
+	}
+
+	l := NewLogger(buf, LevelDebug)
+	l.SetUseColors(true)
+	l.Error("failed")
+	l.SetPalette(Palette{LevelInfo: {Prefix: "32"}})
+	l.Info("ok")
+	l.Error("plain")
+	out := buf.String()
+	if !strings.HasPrefix(out, "\x1b[1;91m    err ") || !strings.Contains(out, "\x1b[0m \x1b[91mfailed\x1b[0m\n") ||
+		!strings.Contains(out, "\x1b[32m    inf ") || !strings.Contains(out, "\n    err ") || !strings.Contains(out, "] plain\n") {
+		t.Fatalf("unexpected output: %q", out)
+	}
+}
+
+func TestLoggerTimeFormat(t *testing.T) {
+	l, buf := newTestLogger(LevelDebug)
+	l.SetUTC(true)
+	l.SetTimeFormat(TimeRFC3339)
+	l.Info("rfc")
+	l.SetTimeFormat(TimeElapsed)
+	l.Info("elapsed")
+	l.SetTimeFormat("15:04 MST")
+	l.Info("custom")
+	l.SetEncoder(JSONEncoder{})
+	l.Info("json")
+	lines := strings.Split(buf.String(), "\n")
+	if len(lines) != 5 ||
+		!strings.HasPrefix(lines[0], "    inf "+time.Now().UTC().Format("2006-01-02T")) || !strings.Contains(lines[0], "Z [") ||
+		!strings.HasPrefix(lines[1], "    inf +00:") ||
+		!strings.Contains(lines[2], " UTC [") ||
+		!strings.Contains(lines[3], `Z","level":"inf"`) {
+		t.Fatalf("unexpected output: %q", buf.String())
+	}
+}
+
+func TestLoggerHooks(t *testing.T) {
+	l, _ := newTestLogger(LevelDebug)
+	unblock := make(chan struct{})
+	got := make(chan *Record, 4)
+	l.AddHook(func(r *Record) {
+		<-unblock
+		got <- r
+	}, HookQueueSize(1))
+	queue := l.hooks[0].queue
+
+	// Первая запись забирается hook'ом и блокирует его, вторая ждет в очереди, третья выбрасывается
+	l.Named("pdg").Warn("skipped")
+	l.Named("pdg").With("id", 5).Errorf("first: %v", io.EOF)
+	for len(queue) > 0 {
+		runtime.Gosched()
+	}
+	l.Error("second")
+	l.Error("third")
+	if dropped := l.HooksDropped(); dropped != 1 {
+		t.Fatalf("expected 1 dropped record, got %v", dropped)
+	}
+	close(unblock)
+	first, second := <-got, <-got
+	if first.Message != "first: EOF" || first.Err != io.EOF || first.Name != "pdg" || len(first.Fields) != 1 ||
+		!strings.HasSuffix(first.File, "logger_test.go") || second.Message != "second" {
+		t.Fatalf("unexpected records: %+v, %+v", first, second)
+	}
+	select {
+	case r := <-got:
+		t.Fatalf("unexpected record: %+v", r)
+	case <-time.After(10 * time.Millisecond):
+	}
+}
+
+func TestLoggerMetrics(t *testing.T) {
+	l, _ := newTestLogger(LevelInfo)
+	l.SetFallbackWriter(nil)
+	pdg := l.Named("pdg")
+	for i := 0; i < 3; i++ {
+		pdg.Error("put failed")
+	}
+	l.Info("started")
+	l.Debug("filtered")
+	l.AddWriter(&failingWriter{err: fmt.Errorf("disk full")})
+	l.Warn("lost")
+
+	rec := httptest.NewRecorder()
+	l.MetricsHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	body := rec.Body.String()
+	for _, line := range []string{
+		`log_records_total{logger="",level="info"} 1`,
+		`log_records_total{logger="",level="debug"} 0`,
+		`log_records_total{logger="",level="warn"} 1`,
+		`log_records_total{logger="pdg",level="error"} 3`,
+		`log_errors_by_caller_total{logger="pdg",caller="logger_test.go:`,
+		`log_dropped_records_total{queue="async"} 0`,
+		`log_suppressed_records_total 0`,
+		`log_write_errors_total{writer="*common.failingWriter"} 1`,
+	} {
+		if !strings.Contains(body, line) {
+			t.Fatalf("%q not found in metrics:\n%v", line, body)
+		}
+	}
+}
+
+// warnWrapper обертка над логгером, как в библиотеках-помощниках
+func warnWrapper(l *Logger, s ...interface{}) {
+	l.Skip(1).Warn(s...)
+}
+
+// errorWrapper обертка другой библиотеки с двумя уровнями вложенности
+func errorWrapper(l *Logger, s ...interface{}) {
+	errorWrapperImpl(l, s...)
+}
+
+func errorWrapperImpl(l *Logger, s ...interface{}) {
+	l.Skip(2).Error(s...)
+}
+
+func TestLoggerCaller(t *testing.T) {
+	l, buf := newTestLogger(LevelDebug)
+	var wg sync.WaitGroup
+	_, _, line, _ := runtime.Caller(0)
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			warnWrapper(l, "wrapped") // line+5
+			l.At("remote.go", 42).Info("explicit")
+		}()
+	}
+	wg.Wait()
+	l.SetFuncName(true)
+	l.Info("with func")
+	out := buf.String()
+	wrapperLine := fmt.Sprintf("[logger_test.go:%v] wrapped\n", line+5)
+	if strings.Count(out, wrapperLine) != 4 || strings.Count(out, "[remote.go:42] explicit\n") != 4 ||
+		!strings.Contains(out, " common.TestLoggerCaller] with func\n") {
+		t.Fatalf("unexpected output: %q", out)
+	}
+}
+
+func TestLoggerRedact(t *testing.T) {
+	type credentials struct {
+		User     string
+		Password string `log:"secret"`
+		Key      []byte `log:"secret"`
+	}
+	type config struct {
+		Name string
+		DB   credentials
+	}
+	l, buf := newTestLogger(LevelDebug)
+	l.Redact(RedactDefaults...)
+	l.Redact("s3cr3t-value")
+	token := "123456789:AAHdqTcvCH1vGWJxfSeofSAs0K5PALDsawQ"
+	cfg := config{Name: "bot", DB: credentials{User: "admin", Password: "hunter2", Key: []byte{1}}}
+	l.Info("GET https://api.telegram.org/bot" + token + "/sendMessage")
+	l.With("url", "http://db/?user=admin&password=hunter2").Warnf("connect %v", "Authorization: Bearer abc.def")
+	l.Errorf("config %+v", &cfg)
+	l.With("cfg", cfg).Info("value s3cr3t-value")
+	endpoint, _ := url.Parse("https://api.telegram.org/bot" + token + "/getUpdates")
+	l.With("url", endpoint).Print("polling ", cfg)
+	got := make(chan *Record, 1)
+	l.AddHook(func(r *Record) { got <- r })
+	sendErr := &url.Error{Op: "Post", URL: endpoint.String(), Err: io.EOF}
+	l.Error("send failed: ", sendErr)
+	r := <-got
+	if strings.Contains(r.Err.Error(), token) || !errors.Is(r.Err, io.EOF) {
+		t.Fatalf("record error is not redacted: %v", r.Err)
+	}
+	out := buf.String()
+	for _, secret := range []string{token, "hunter2", "abc.def", "s3cr3t-value", "Key:[1]"} {
+		if strings.Contains(out, secret) {
+			t.Fatalf("secret %q is not redacted: %q", secret, out)
+		}
+	}
+	for _, expected := range []string{"/bot***/sendMessage", "password=***", "Bearer ***", "Password:*** Key:[]", "User:admin", "value ***",
+		"polling {bot {admin *** []}} url=https://api.telegram.org/bot***/getUpdates"} {
+		if !strings.Contains(out, expected) {
+			t.Fatalf("expected %q in output: %q", expected, out)
+		}
+	}
+	if cfg.DB.Password != "hunter2" {
+		t.Fatal("original struct must not be modified")
+	}
+}
+
+func TestLoggerFingersCrossed(t *testing.T) {
+	l, buf := newTestLogger(LevelInfo)
+
+	// Область без ошибок: отладочные записи отбрасываются
+	scoped, end := l.FingersCrossed()
+	scoped.Debug("quiet debug")
+	scoped.Info("quiet info")
+	end()
+	scoped.Error("after end")
+
+	// Область с ошибкой: отложенные записи выводятся перед ней, старые вытесняются
+	ctx, end := ContextWithFingersCrossed(ContextWithFields(context.Background(), "update", 7), FingersCrossedSize(2))
+	for i := 1; i <= 3; i++ {
+		l.DebugCtx(ctx, "step ", i)
+	}
+	l.Named("api").ErrorCtx(ctx, "failed")
+	l.DebugCtx(ctx, "after error")
+	end()
+	l.Debug("outside")
+
+	out := buf.String()
+	for _, unexpected := range []string{"quiet debug", "step 1", "outside"} {
+		if strings.Contains(out, unexpected) {
+			t.Fatalf("unexpected %q in output: %q", unexpected, out)
+		}
+	}
+	expected := []string{"quiet info", "after end", "... 1 earlier records dropped", "step 2", "step 3", "failed", "after error"}
+	last := -1
+	for _, message := range expected {
+		idx := strings.Index(out, message)
+		if idx <= last {
+			t.Fatalf("expected %q in order in output: %q", message, out)
+		}
+		last = idx
+	}
+	if strings.Count(out, "update=7") != 5 {
+		t.Fatalf("expected context fields in scoped records: %q", out)
+	}
+}
+
+func TestLoggerTypedFields(t *testing.T) {
+	l, buf := newTestLogger(LevelInfo)
+	evaluated := false
+	l.LogFn(LevelDebug, func() string { evaluated = true; return "lazy" })
+	l.Debug("lazy ", func() string { evaluated = true; return "arg" })
+	if evaluated || buf.Len() != 0 || l.Enabled(LevelDebug) || !l.Enabled(LevelInfo) {
+		t.Fatalf("disabled level must not be evaluated: %q", buf.String())
+	}
+	fields := []TypedField{String("s", "v"), Uint64("u", 2), Float64("f", 1.5)}
+	fields = append(fields, Bool("b", true), Duration("d", time.Second), Any("a", []int{1}))
+	l.With(Int("n", -1)).Log(LevelWarn, "typed", fields...)
+	l.Info("lazy ", func() string { return "arg" })
+	out := buf.String()
+	if !strings.Contains(out, "] typed n=-1 s=v u=2 f=1.5 b=true d=1s a=[1]\n") || !strings.Contains(out, "] lazy arg\n") {
+		t.Fatalf("unexpected output: %q", out)
+	}
+
+	n := 42
+	allocs := testing.AllocsPerRun(100, func() {
+		l.Debug("const")
+		l.Log(LevelDebug, "update", Int("n", n), String("s", "v"))
+		l.LogFn(LevelDebug, func() string { return fmt.Sprint(n) })
+	})
+	if allocs != 0 {
+		t.Fatalf("disabled level allocates: %v", allocs)
+	}
+}
+
+func BenchmarkLoggerDisabled(b *testing.B) {
+	l := NewLogger(ioutil.Discard, LevelInfo)
+	n := 42
+	b.Run("Debug", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			l.Debug("update ", n)
+		}
+	})
+	b.Run("Log", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			l.Log(LevelDebug, "update", Int("n", n), String("s", "v"))
+		}
+	})
+	b.Run("LogFn", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			l.LogFn(LevelDebug, func() string { return fmt.Sprint("update ", n) })
+		}
+	})
+}
+
+func BenchmarkLoggerEnabled(b *testing.B) {
+	l := NewLogger(ioutil.Discard, LevelInfo)
+	n := 42
+	b.Run("Info", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			l.Info("update ", n)
+		}
+	})
+	b.Run("Log", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			l.Log(LevelInfo, "update", Int("n", n), String("s", "v"))
+		}
+	})
+}
+
+func TestLoggerCallerWrappers(t *testing.T) {
+	l, buf := newTestLogger(LevelDebug)
+	legacy := l.With()
+	legacy.SetCallStackAdder(1)
+	var wg sync.WaitGroup
+	_, _, line, _ := runtime.Caller(0)
+	for i := 0; i < 8; i++ {
+		wg.Add(3)
+		go func() {
+			defer wg.Done()
+			warnWrapper(l, "warn wrapper") // line+5
+		}()
+		go func() {
+			defer wg.Done()
+			errorWrapper(l, "error wrapper") // line+9
+		}()
+		go func() {
+			defer wg.Done()
+			warnWrapper(legacy.With(), "legacy") // line+13
+			l.Info("direct")                     // line+14
+		}()
+	}
+	wg.Wait()
+	out := buf.String()
+	for message, offset := range map[string]int{"warn wrapper": 5, "error wrapper": 9, "direct": 14} {
+		if expected := fmt.Sprintf("[logger_test.go:%v] %v\n", line+offset, message); strings.Count(out, expected) != 8 {
+			t.Fatalf("expected %q 8 times in output: %q", expected, out)
+		}
+	}
+	// SetCallStackAdder действует только на legacy: warnWrapper пропускает еще один кадр
+	if strings.Count(out, "] legacy\n") != 8 || strings.Contains(out, fmt.Sprintf("[logger_test.go:%v] legacy\n", line+13)) {
+		t.Fatalf("unexpected legacy caller: %q", out)
+	}
+}
+
+func TestLoggerRedactNested(t *testing.T) {
+	type secret struct {
+		Name  string
+		Token string `log:"secret"`
+		pin   string `log:"secret"`
+	}
+	type node struct {
+		Secret *secret
+		Next   *node
+	}
+	type holder struct {
+		List  []secret
+		ByBot map[string]*secret
+		Any   interface{}
+		Err   error
+		Head  *node
+	}
+	value := holder{
+		List:  []secret{{Name: "a", Token: "tok-1", pin: "1111"}},
+		ByBot: map[string]*secret{"b": {Name: "b", Token: "tok-2"}},
+		Any:   secret{Name: "c", Token: "tok-3"},
+		Err:   fmt.Errorf("plain error"),
+		Head:  &node{Secret: &secret{Name: "d", Token: "tok-4"}},
+	}
+	value.Head.Next = value.Head
+	l, buf := newTestLogger(LevelDebug)
+	l.Infof("%+v", value)
+	l.With("holder", &value).Info("fields")
+	out := buf.String()
+	for _, secret := range []string{"tok-1", "1111", "tok-2", "tok-3", "tok-4"} {
+		if strings.Contains(out, secret) {
+			t.Fatalf("secret %q is not redacted: %q", secret, out)
+		}
+	}
+	if !strings.Contains(out, "Name:a Token:*** pin:***") || !strings.Contains(out, "Any:{Name:c Token:*** pin:}") || !strings.Contains(out, "plain error") {
+		t.Fatalf("unexpected output: %q", out)
+	}
+	if value.List[0].Token != "tok-1" || value.List[0].pin != "1111" || value.ByBot["b"].Token != "tok-2" {
+		t.Fatal("original value must not be modified")
+	}
+
+	// Первое форматирование типа из нескольких горутин сразу
+	type fresh struct {
+		Password string `log:"secret"`
+	}
+	buf.Reset()
+	var wg sync.WaitGroup
+	for i := 0; i < 16; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			l.Infof("%+v", fresh{Password: "hunter2"})
+		}()
+	}
+	wg.Wait()
+	if strings.Contains(buf.String(), "hunter2") {
+		t.Fatalf("secret is not redacted: %q", buf.String())
+	}
+}
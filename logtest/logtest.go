@@ -0,0 +1,81 @@
+// Package logtest логгер для тестов: вывод показывается только для упавших тестов,
+// по записям можно проверять, что было (или не было) залоггировано
+package logtest
+
+import (
+	"testing"
+
+	"github.com/bots-for-me/common"
+)
+
+// DefaultRecords сколько записей хранит Recorder по умолчанию
+const DefaultRecords = 10000
+
+// Recorder логгер, привязанный к тесту
+// Fatal вызывает common.Exit и завершает процесс, в тестах его использовать нельзя
+type Recorder struct {
+	*common.Logger
+	Memory *common.MemoryWriter
+	t      testing.TB
+}
+
+// New создает логгер уровня LevelDebug для теста t
+// Если тест упал, записи выводятся через t.Log по его завершении
+//	* t			- testing.TB
+//	* params	- int (сколько записей хранить, по умолчанию DefaultRecords)
+func New(t testing.TB, params ...interface{}) *Recorder {
+	size := DefaultRecords
+	for _, param := range params {
+		switch param := param.(type) {
+		case int:
+			size = param
+		}
+	}
+	memory := common.NewMemoryWriter(size)
+	r := &Recorder{Logger: common.NewLogger(memory, common.LevelDebug), Memory: memory, t: t}
+	t.Cleanup(func() {
+		if t.Failed() {
+			r.Dump()
+		}
+	})
+	return r
+}
+
+// Dump выводит записи через t.Log
+func (r *Recorder) Dump() {
+	r.t.Helper()
+	var buf []byte
+	r.Memory.Each(func(record *common.Record) bool {
+		buf = common.TextEncoder{}.Encode(buf, record, false)
+		return true
+	})
+	if len(buf) > 0 {
+		r.t.Logf("log:\n%s", buf)
+	}
+}
+
+// Logged проверяет, есть ли запись, подходящая под фильтры
+//	* filters - как в common.MemoryWriter.Records: минимальный уровень (common.LevelError),
+//				подстрока сообщения, func(r *common.Record) bool
+func (r *Recorder) Logged(filters ...interface{}) bool {
+	return r.Memory.Contains(filters...)
+}
+
+// AssertLogged помечает тест упавшим, если подходящей под фильтры записи нет:
+// rec.AssertLogged(common.LevelError, "connection refused")
+//	* filters - см. Logged
+func (r *Recorder) AssertLogged(filters ...interface{}) {
+	r.t.Helper()
+	if !r.Memory.Contains(filters...) {
+		r.t.Errorf("no log record matching %v", filters)
+	}
+}
+
+// AssertNotLogged помечает тест упавшим, если есть запись, подходящая под фильтры
+//	* filters - см. Logged
+func (r *Recorder) AssertNotLogged(filters ...interface{}) {
+	r.t.Helper()
+	if records := r.Memory.Records(filters...); len(records) > 0 {
+		r.t.Errorf("unexpected log record matching %v: %v", filters, records[0].Message)
+	}
+}
@@ -0,0 +1,35 @@
+package logtest
+
+import (
+	"testing"
+
+	"github.com/bots-for-me/common"
+)
+
+// fakeT запоминает ошибки вместо того, чтобы ронять тест
+type fakeT struct {
+	testing.TB
+	errors int
+}
+
+func (t *fakeT) Helper()                                   {}
+func (t *fakeT) Cleanup(func())                            {}
+func (t *fakeT) Errorf(format string, args ...interface{}) { t.errors++ }
+
+func TestRecorder(t *testing.T) {
+	rec := New(t)
+	rec.Named("pdg").With("id", 5).Errorf("put failed: %v", "disk full")
+	rec.Info("done")
+	rec.AssertLogged(common.LevelError, "disk full")
+	rec.AssertNotLogged(common.LevelWarn, "done")
+	if !rec.Logged(func(r *common.Record) bool { return r.Name == "pdg" && len(r.Fields) == 1 }) {
+		t.Fatal("record with name and fields not found")
+	}
+
+	inner := &fakeT{}
+	rec = New(inner)
+	rec.AssertLogged("missing")
+	if inner.errors != 1 {
+		t.Fatal("AssertLogged must fail the test")
+	}
+}
@@ -33,7 +33,8 @@ func Errorf(s ...interface{}) (err error) {
 	}
 	if first, ok := s[0].(string); ok && len(s) > 1 && strings.Contains(first, "%") {
 		// str = fmt.Sprintf(first, s[1:]...)
-		s[0] = GetCurrentFileAndLine(2)
+		// s копируется, чтобы не менять срез вызывающего (Errorf(format, args...))
+		s = append([]interface{}{GetCurrentFileAndLine(2)}, s[1:]...)
 		if first[0] == '[' {
 			err = fmt.Errorf("[%v]"+first, s...)
 		} else {
@@ -51,6 +52,7 @@ func Errorf(s ...interface{}) (err error) {
 		// str = fmt.Sprint(s...)
 		err = fmt.Errorf("[%v]: %v", GetCurrentFileAndLine(2), fmt.Sprint(s...))
 	}
+	err = withStack(err, 1)
 	return
 }
 
@@ -126,7 +126,7 @@ func (w *WaitChans) Wait(args ...time.Duration) {
 					// Log.Debug("WaitChans.Wait() wait %v for %#v", timeout, ch.from)
 					ch.waitChan <- tmp
 					timer := time.AfterFunc(timeout, func() {
-						Log.Warn("WaitChans.Wait() timeout (%v, added in %#v)", timeout, ch.from)
+						Log.Warnf("WaitChans.Wait() timeout (%v, added in %#v)", timeout, ch.from)
 						wg.Done()
 					})
 					// ts := time.Now()
@@ -1,7 +1,6 @@
 package common
 
 import (
-	"log"
 	"os"
 	"path/filepath"
 	"time"
@@ -10,6 +9,7 @@ import (
 )
 
 func WatchChanges(dir string, testDir, testPath func(str string) bool, cb func(path string)) (err error) {
+	watchLog := Log.Named("watch")
 	var reloadWatcher *fsnotify.Watcher
 	reloadWatcher, err = fsnotify.NewWatcher()
 	if err != nil {
@@ -38,18 +38,18 @@ func WatchChanges(dir string, testDir, testPath func(str string) bool, cb func(p
 			select {
 			case event := <-reloadWatcher.Events:
 				if testPath(event.Name) {
-					Log.Verbose("watch event for '%v': %s", event.Name, event.Op)
+					watchLog.Verbosef("watch event for '%v': %s", event.Name, event.Op)
 					matched[event.Name] = time.Now()
 				}
 			case err := <-reloadWatcher.Errors:
 				if err != nil {
-					log.Fatal(err)
+					watchLog.Fatal(err)
 				}
 			case <-ticker.C:
 				for key, ts := range matched {
 					if time.Since(ts) > 2*time.Second {
 						delete(matched, key)
-						Log.Info("watch catched '%v'", key)
+						watchLog.Infof("watch catched '%v'", key)
 						cb(key)
 					}
 				}
@@ -18,7 +18,7 @@ func WatchSrc(ignore ...string) {
 		func(dir string) bool { return !reBadDirs.MatchString(dir) && (re == nil || !re.MatchString(dir)) },
 		func(path string) bool { return reGoodPath.MatchString(path) },
 		func(path string) {
-			Log.Info("file '%v' changed - exiting", path)
+			Log.Infof("file '%v' changed - exiting", path)
 			time.Sleep(time.Second)
 			Exit()
 		},